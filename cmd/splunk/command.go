@@ -0,0 +1,300 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jimmyjames85/splunkcli/pkg/splunk"
+	"github.com/pkg/errors"
+)
+
+// Exit codes shared by every command
+const (
+	exitOK          = 0
+	exitFailure     = 1
+	exitUsage       = 2
+	exitAuth        = 3
+	exitInterrupted = 130
+)
+
+// command is a node in the cli's command tree. A command either runs
+// itself or dispatches to one of its subcommands
+type command struct {
+	name        string
+	args        string // synopsis of the positional arguments
+	short       string
+	flags       *flag.FlagSet
+	run         func(args []string) error
+	subcommands []*command
+	parent      *command
+	pairs       map[string]bool // flags that take two values, see pairFlag
+}
+
+func newCommand(name, args, short string) *command {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard) // errors and help are printed by execute
+	return &command{name: name, args: args, short: short, flags: fs}
+}
+
+func (c *command) add(subs ...*command) *command {
+	for _, sub := range subs {
+		sub.parent = c
+		c.subcommands = append(c.subcommands, sub)
+	}
+	return c
+}
+
+func (c *command) find(name string) *command {
+	for _, sub := range c.subcommands {
+		if sub.name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// path is the full invocation, e.g. "splunk job cancel"
+func (c *command) path() string {
+	if c.parent == nil {
+		return c.name
+	}
+	return c.parent.path() + " " + c.name
+}
+
+// exitError is an error that carries the process exit code
+type exitError struct {
+	code int
+	msg  string
+}
+
+func (e *exitError) Error() string { return e.msg }
+
+func usageErrorf(format string, a ...interface{}) error {
+	return &exitError{code: exitUsage, msg: fmt.Sprintf(format, a...)}
+}
+
+func interruptedf(format string, a ...interface{}) error {
+	return &exitError{code: exitInterrupted, msg: fmt.Sprintf(format, a...)}
+}
+
+// requireArgs returns a usage error unless at least n positional args were given
+func requireArgs(args []string, n int, what string) error {
+	if len(args) < n {
+		return usageErrorf("please provide %s", what)
+	}
+	return nil
+}
+
+// parseFlags parses flags that may appear before, between or after
+// positional arguments. Everything following "--" is positional
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional, rest []string
+	for i, a := range args {
+		if a == "--" {
+			args, rest = args[:i], args[i+1:]
+			break
+		}
+	}
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	return append(positional, rest...), nil
+}
+
+// pairValue holds the two values of a flag defined with pairFlag
+type pairValue []string
+
+func (p *pairValue) String() string { return strings.Join(*p, " ") }
+
+func (p *pairValue) Set(s string) error {
+	if len(*p) == 2 {
+		return errors.New("takes exactly two values")
+	}
+	*p = append(*p, s)
+	return nil
+}
+
+// pairFlag defines a flag given as --name A B
+func (c *command) pairFlag(name, usage string) *pairValue {
+	p := &pairValue{}
+	c.flags.Var(p, name, usage)
+	if c.pairs == nil {
+		c.pairs = map[string]bool{}
+	}
+	c.pairs[name] = true
+	return p
+}
+
+// expandPairs rewrites --name A B as --name A --name B for the flags
+// defined with pairFlag, which the flag package then sets twice
+func (c *command) expandPairs(args []string) []string {
+	var ret []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			return append(ret, args[i:]...)
+		}
+		if strings.HasPrefix(a, "-") && c.pairs[strings.TrimLeft(a, "-")] && i+2 < len(args) {
+			ret = append(ret, a, args[i+1], a, args[i+2])
+			i += 2
+			continue
+		}
+		ret = append(ret, a)
+	}
+	return ret
+}
+
+// execute parses `args` for c, descends into subcommands and runs the
+// selected command
+func (c *command) execute(args []string) error {
+	var err error
+	if len(c.subcommands) > 0 {
+		// flags of a parent command must come before the subcommand
+		err = c.flags.Parse(args)
+		args = c.flags.Args()
+	} else {
+		args, err = parseFlags(c.flags, c.expandPairs(args))
+	}
+	if err == flag.ErrHelp {
+		c.printHelp(os.Stdout)
+		return nil
+	}
+	if err != nil {
+		return usageErrorf("%s\nRun '%s --help' for usage.", err.Error(), c.path())
+	}
+
+	if len(c.subcommands) > 0 {
+		if len(args) == 0 {
+			if c.run != nil {
+				return c.run(args)
+			}
+			c.printHelp(os.Stderr)
+			return &exitError{code: exitUsage}
+		}
+		sub := c.find(args[0])
+		if sub == nil {
+			return usageErrorf("unknown command: %s\nRun '%s --help' for usage.", args[0], c.path())
+		}
+		return sub.execute(args[1:])
+	}
+	return c.run(args)
+}
+
+func (c *command) printHelp(w io.Writer) {
+	synopsis := c.path()
+	if hasFlags(c.flags) {
+		synopsis += " [flags]"
+	}
+	if len(c.subcommands) > 0 {
+		synopsis += " <command>"
+	}
+	if c.args != "" {
+		synopsis += " " + c.args
+	}
+	fmt.Fprintf(w, "Usage: %s\n\n%s\n", synopsis, c.short)
+
+	if len(c.subcommands) > 0 {
+		fmt.Fprintf(w, "\nCommands:\n")
+		width := 0
+		for _, sub := range c.subcommands {
+			if len(sub.name) > width {
+				width = len(sub.name)
+			}
+		}
+		for _, sub := range c.subcommands {
+			fmt.Fprintf(w, "  %-*s  %s\n", width, sub.name, sub.short)
+		}
+	}
+	if hasFlags(c.flags) {
+		fmt.Fprintf(w, "\nFlags:\n")
+		printFlags(w, c.flags)
+	}
+	if c.parent == nil {
+		fmt.Fprintf(w, "\nExit codes: %d ok, %d error, %d usage, %d authentication, %d interrupted\n", exitOK, exitFailure, exitUsage, exitAuth, exitInterrupted)
+	}
+	if len(c.subcommands) > 0 {
+		fmt.Fprintf(w, "\nRun '%s <command> --help' for more about a command.\n", c.path())
+	}
+}
+
+func hasFlags(fs *flag.FlagSet) bool {
+	n := 0
+	fs.VisitAll(func(*flag.Flag) { n++ })
+	return n > 0
+}
+
+func printFlags(w io.Writer, fs *flag.FlagSet) {
+	fs.VisitAll(func(f *flag.Flag) {
+		name, usage := flag.UnquoteUsage(f)
+		line := "  --" + f.Name
+		if name != "" {
+			line += " " + name
+		}
+		fmt.Fprintf(w, "%s\n      %s", line, usage)
+		if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" && f.DefValue != "0s" {
+			fmt.Fprintf(w, " (default %q)", f.DefValue)
+		}
+		fmt.Fprintln(w)
+	})
+}
+
+// flagNames returns the names of every flag defined on fs, sorted
+func flagNames(fs *flag.FlagSet) []string {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+	sort.Strings(names)
+	return names
+}
+
+// exitCode maps an error returned by a command to its message and exit code
+func exitCode(err error) (int, string) {
+	switch e := err.(type) {
+	case nil:
+		return exitOK, ""
+	case *exitError:
+		return e.code, e.msg
+	case *splunk.APIError:
+		if e.StatusCode == http.StatusUnauthorized {
+			return exitAuth, "auth failed: perhaps session expired, run 'splunk login'"
+		}
+	}
+	switch cause := errors.Cause(err); {
+	case err == splunk.ErrAuth:
+		return exitAuth, "auth failed: perhaps session expired, run 'splunk login'"
+	case splunk.IsAuth(cause): // e.g. a failed login, whose message says so
+		return exitAuth, err.Error()
+	case splunk.IsCanceled(cause):
+		return exitInterrupted, "interrupted"
+	}
+	return exitFailure, err.Error()
+}
+
+func helpCmd(root *command) *command {
+	c := newCommand("help", "[command...]", "Show help for a command")
+	c.run = func(args []string) error {
+		cmd := root
+		for _, name := range args {
+			sub := cmd.find(name)
+			if sub == nil {
+				return usageErrorf("unknown command: %s", strings.Join(args, " "))
+			}
+			cmd = sub
+		}
+		cmd.printHelp(os.Stdout)
+		return nil
+	}
+	return c
+}
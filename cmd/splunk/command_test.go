@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jimmyjames85/splunkcli/pkg/splunk"
+	"github.com/pkg/errors"
+)
+
+func TestExitCode(t *testing.T) {
+	unauthorized := &splunk.APIError{Method: "GET", Endpoint: "/services/authentication/current-context", StatusCode: http.StatusUnauthorized}
+	tests := []struct {
+		err  error
+		code int
+		msg  string // a part of the message
+	}{
+		{nil, exitOK, ""},
+		{usageErrorf("unknown flag"), exitUsage, "unknown flag"},
+		{splunk.ErrAuth, exitAuth, "run 'splunk login'"},
+		{unauthorized, exitAuth, "run 'splunk login'"},
+		{errors.Wrapf(unauthorized, "unable to authenticate with %s", "https://sh:8089"), exitAuth, "unable to authenticate with https://sh:8089: GET"},
+		{errors.Wrap(&url.Error{Op: "Get", URL: "https://sh:8089", Err: context.Canceled}, "saved search a"), exitInterrupted, "interrupted"},
+		{errors.New("boom"), exitFailure, "boom"},
+	}
+	for _, tt := range tests {
+		code, msg := exitCode(tt.err)
+		if code != tt.code || !strings.Contains(msg, tt.msg) {
+			t.Errorf("exitCode(%v) = %d, %q, want %d, %q", tt.err, code, msg, tt.code, tt.msg)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+func completionCmd(root *command) *command {
+	c := newCommand("completion", "bash|zsh|fish", "Print a shell completion script")
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "a shell: bash, zsh or fish"); err != nil {
+			return err
+		}
+		var script string
+		switch args[0] {
+		case "bash":
+			script = bashCompletion(root)
+		case "zsh":
+			script = zshCompletion(root)
+		case "fish":
+			script = fishCompletion(root)
+		default:
+			return usageErrorf("unsupported shell: %s", args[0])
+		}
+		fmt.Fprint(os.Stdout, script)
+		return nil
+	}
+	return c
+}
+
+// completionCase is what to offer once the subcommands typed so far
+// form `words`
+type completionCase struct {
+	words string
+	cmd   *command
+}
+
+// completionCases walks the command tree, deepest commands first
+func completionCases(root *command) []completionCase {
+	var cases []completionCase
+	var walk func(c *command, words string)
+	walk = func(c *command, words string) {
+		cases = append(cases, completionCase{words: words, cmd: c})
+		for _, sub := range c.subcommands {
+			walk(sub, words+" "+sub.name)
+		}
+	}
+	walk(root, "")
+	sort.SliceStable(cases, func(i, j int) bool {
+		return strings.Count(cases[i].words, " ") > strings.Count(cases[j].words, " ")
+	})
+	return cases
+}
+
+// flagWord is how flag `name` is typed: -f for single letters, --name
+// otherwise
+func flagWord(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
+// flagValues is the number of values that follow flag `f` of c
+func flagValues(c *command, f *flag.Flag) int {
+	if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		return 0
+	}
+	if c.pairs[f.Name] {
+		return 2
+	}
+	return 1
+}
+
+// completionWords lists the subcommands and flags offered for c
+func completionWords(c *command) []string {
+	var words []string
+	for _, sub := range c.subcommands {
+		words = append(words, sub.name)
+	}
+	for _, name := range flagNames(c.flags) {
+		words = append(words, flagWord(name))
+	}
+	return words
+}
+
+// completionPatterns returns the shell case patterns matching the
+// subcommands typed so far, and those matching a flag of theirs, in
+// either form, followed by 1 or 2 values
+func completionPatterns(root *command) (commands string, values map[int]string) {
+	var cmds []string
+	valuePatterns := map[int][]string{}
+	for _, cc := range completionCases(root) {
+		if cc.words != "" {
+			cmds = append(cmds, fmt.Sprintf("%q", cc.words))
+		}
+		cc.cmd.flags.VisitAll(func(f *flag.Flag) {
+			if n := flagValues(cc.cmd, f); n > 0 {
+				valuePatterns[n] = append(valuePatterns[n], fmt.Sprintf("%q|%q", cc.words+" -"+f.Name, cc.words+" --"+f.Name))
+			}
+		})
+	}
+	values = map[int]string{}
+	for n, patterns := range valuePatterns {
+		values[n] = strings.Join(patterns, "|")
+	}
+	return strings.Join(cmds, "|"), values
+}
+
+// Both scripts walk the words before the cursor, extending cmdpath with
+// the subcommands and skipping flags along with their values. When the
+// cursor is on a flag value, files are completed
+const shellWalk = `        case "$w" in
+            -*=*) ;;
+            -*)
+                case "$cmdpath $w" in
+                    %[2]s) ((i++)) ;;
+                    %[3]s) ((i += 2)) ;;
+                esac ;;
+            *)
+                case "$cmdpath $w" in
+                    %[1]s) cmdpath="$cmdpath $w" ;;
+                esac ;;
+        esac
+`
+
+func bashCompletion(root *command) string {
+	cmds, values := completionPatterns(root)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `# bash completion for %[1]s
+# source <(%[1]s completion bash)
+_%[1]s() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" cmdpath="" opts="" w i
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        w="${COMP_WORDS[i]}"
+`, root.name)
+	fmt.Fprintf(&buf, shellWalk, cmds, values[1], values[2])
+	fmt.Fprintf(&buf, `    done
+    ((i > COMP_CWORD)) && return
+    case "$cmdpath" in
+`)
+	for _, cc := range completionCases(root) {
+		fmt.Fprintf(&buf, "        %q) opts=%q ;;\n", cc.words, strings.Join(completionWords(cc.cmd), " "))
+	}
+	fmt.Fprintf(&buf, `    esac
+    COMPREPLY=($(compgen -W "$opts" -- "$cur"))
+}
+complete -o default -F _%[1]s %[1]s
+`, root.name)
+	return buf.String()
+}
+
+func zshCompletion(root *command) string {
+	cmds, values := completionPatterns(root)
+	var buf bytes.Buffer
+	// $path is special in zsh, hence cmdpath
+	fmt.Fprintf(&buf, `#compdef %[1]s
+# source <(%[1]s completion zsh)
+_%[1]s() {
+    local cmdpath="" w i
+    local -a opts
+    for ((i = 2; i < CURRENT; i++)); do
+        w="${words[i]}"
+`, root.name)
+	fmt.Fprintf(&buf, shellWalk, cmds, values[1], values[2])
+	fmt.Fprintf(&buf, `    done
+    if ((i > CURRENT)); then
+        _files
+        return
+    fi
+    case "$cmdpath" in
+`)
+	for _, cc := range completionCases(root) {
+		fmt.Fprintf(&buf, "        %q) opts=(%s) ;;\n", cc.words, strings.Join(completionWords(cc.cmd), " "))
+	}
+	fmt.Fprintf(&buf, `    esac
+    compadd -- $opts
+    _files
+}
+compdef _%[1]s %[1]s
+`, root.name)
+	return buf.String()
+}
+
+func fishCompletion(root *command) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# fish completion for %[1]s\n# %[1]s completion fish | source\n", root.name)
+	// like __fish_use_subcommand, but the values of root flags are not
+	// taken for a subcommand
+	var rootValues []string
+	root.flags.VisitAll(func(f *flag.Flag) {
+		if flagValues(root, f) > 0 {
+			rootValues = append(rootValues, "-"+f.Name, "--"+f.Name)
+		}
+	})
+	noSubcommand := "__" + root.name + "_use_subcommand"
+	fmt.Fprintf(&buf, `function %s
+    set -l words (commandline -opc)
+    set -e words[1]
+    while set -q words[1]
+        switch $words[1]
+            case %s
+                set -e words[1]
+            case '-*'
+            case '*'
+                return 1
+        end
+        set -e words[1]
+    end
+end
+`, noSubcommand, strings.Join(rootValues, " "))
+	var walk func(c *command, seen []string)
+	walk = func(c *command, seen []string) {
+		// offer c's subcommands and flags once every name in seen was typed
+		// and none of c's subcommands has been typed yet
+		var conds []string
+		if len(seen) == 0 {
+			conds = append(conds, noSubcommand)
+		}
+		for _, name := range seen {
+			conds = append(conds, "__fish_seen_subcommand_from "+name)
+		}
+		var names []string
+		for _, sub := range c.subcommands {
+			names = append(names, sub.name)
+		}
+		if len(seen) > 0 && len(names) > 0 {
+			conds = append(conds, "not __fish_seen_subcommand_from "+strings.Join(names, " "))
+		}
+		cond := strings.Join(conds, "; and ")
+		for _, sub := range c.subcommands {
+			fmt.Fprintf(&buf, "complete -c %s -n %s -a %s -d %s\n", root.name, fishQuote(cond), sub.name, fishQuote(sub.short))
+		}
+		flagCond := cond
+		if len(seen) == 0 {
+			flagCond = "true"
+		} else if len(names) > 0 {
+			flagCond = strings.Join(conds[:len(conds)-1], "; and ")
+		}
+		c.flags.VisitAll(func(f *flag.Flag) {
+			opt := "-l " + f.Name
+			if len(f.Name) == 1 {
+				opt = "-s " + f.Name
+			}
+			if flagValues(c, f) > 0 {
+				opt += " -r"
+			}
+			fmt.Fprintf(&buf, "complete -c %s -n %s %s -d %s\n", root.name, fishQuote(flagCond), opt, fishQuote(f.Usage))
+		})
+		for _, sub := range c.subcommands {
+			walk(sub, append(append([]string{}, seen...), sub.name))
+		}
+	}
+	walk(root, nil)
+	return buf.String()
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
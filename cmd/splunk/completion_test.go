@@ -0,0 +1,104 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompletionScripts(t *testing.T) {
+	root := rootCmd(&app{})
+	tests := []struct {
+		shell  string
+		script string
+		want   []string
+	}{
+		{"bash", bashCompletion(root), []string{"_splunk() {", "complete -o default -F _splunk splunk",
+			`" saved apply") opts="--app --dry-run -f `, `" saved export") opts="--app --format -o --owner"`,
+			`|" -profile"|" --profile"|`, `" search -between"|" search --between"|`}},
+		{"zsh", zshCompletion(root), []string{"#compdef splunk", "compdef _splunk splunk",
+			`" saved apply") opts=(--app --dry-run -f `, `|" -profile"|" --profile"|`}},
+		{"fish", fishCompletion(root), []string{"function __splunk_use_subcommand",
+			"case -config --config ", "-n '__splunk_use_subcommand' -a search ",
+			"-s f -r -d", "-l profile -r -d", "-l dry-run -d"}},
+	}
+	for _, tt := range tests {
+		for _, want := range tt.want {
+			if !strings.Contains(tt.script, want) {
+				t.Errorf("%s: script lacks %q", tt.shell, want)
+			}
+		}
+		if strings.Contains(tt.script, "--f ") || strings.Contains(tt.script, "--o ") || strings.Contains(tt.script, "-l f ") {
+			t.Errorf("%s: single letter flags offered with two dashes", tt.shell)
+		}
+		// every block opened is closed
+		blocks := map[string][2][]string{
+			"bash": {{"case ", "for ", "_splunk() {"}, {"esac", "done", "}"}},
+			"zsh":  {{"case ", "for ", "if ", "_splunk() {"}, {"esac", "done", "fi", "}"}},
+			"fish": {{"switch ", "while ", "function "}, {"end"}},
+		}[tt.shell]
+		opened, closed := 0, 0
+		for _, line := range strings.Split(tt.script, "\n") {
+			line = strings.TrimSpace(line)
+			for _, open := range blocks[0] {
+				if strings.HasPrefix(line, open) {
+					opened++
+				}
+			}
+			for _, close := range blocks[1] {
+				if line == close || strings.HasPrefix(line, close+" ") {
+					closed++
+				}
+			}
+		}
+		if opened != closed {
+			t.Errorf("%s: %d blocks opened, %d closed", tt.shell, opened, closed)
+		}
+	}
+}
+
+func TestBashCompletion(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	dir, err := ioutil.TempDir("", "splunk-completion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "splunk.bash")
+	if err := ioutil.WriteFile(script, []byte(bashCompletion(rootCmd(&app{}))), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		line string // the words typed, the last one being completed
+		want string
+	}{
+		{"splunk sa", "saved"},
+		{"splunk --profile prod search --l", "--last --latest --lint"},
+		{"splunk --profile=prod saved apply -", "--app --dry-run -f --no-color --prune --yes"},
+		{"splunk search --between -2d -1d --e", "--earliest"},
+		{"splunk job cancel sid1 ", ""},
+		{"splunk saved export --app search -o ", ""},
+		{"splunk --profile prod ", strings.Join(completionWords(rootCmd(&app{})), " ")},
+	}
+	for _, tt := range tests {
+		words := strings.Split(tt.line, " ")
+		var quoted []string
+		for _, w := range words {
+			quoted = append(quoted, "'"+w+"'")
+		}
+		cmd := exec.Command(bash, "--norc", "-c", `source "$1"; COMP_WORDS=(`+strings.Join(quoted, " ")+`); COMP_CWORD=$((${#COMP_WORDS[@]} - 1)); _splunk; echo "${COMPREPLY[*]}"`, "bash", script)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s: %v: %s", tt.line, err, out)
+		}
+		if got := strings.TrimSpace(string(out)); got != tt.want {
+			t.Errorf("%q: completed %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jimmyjames85/splunkcli/pkg/output"
+	"github.com/jimmyjames85/splunkcli/pkg/splunk"
+	"github.com/pkg/errors"
+)
+
+func historyCmd(a *app) *command {
+	c := newCommand("history", "", "List the searches submitted from this machine, newest last")
+	all := c.flags.Bool("all", false, "include the searches of every profile")
+	text := c.flags.String("search", "", "only searches whose SPL contains this text")
+	tag := c.flags.String("tag", "", "only searches with this tag")
+	status := c.flags.String("status", "", "only searches in this state, e.g. done, failed, expired")
+	since := c.flags.String("since", "", "only searches submitted within this long, e.g. 12h or 7d")
+	limit := c.flags.Int("limit", 20, "number of searches to print, the most recent, 0 for all")
+	width := c.flags.Int("width", 60, "truncate the SPL to this many characters, 0 to print it all")
+	c.run = func(args []string) error {
+		var after time.Time
+		if *since != "" {
+			age, err := parseAge(*since)
+			if err != nil {
+				return usageErrorf("invalid --since %q: use a duration such as 12h or 7d", *since)
+			}
+			after = time.Now().Add(-age)
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		entries, err := cli.History.Entries()
+		if err != nil {
+			return err
+		}
+		var matched []splunk.HistoryEntry
+		for _, e := range entries {
+			switch {
+			case !*all && e.Profile != cli.Profile:
+			case *text != "" && !strings.Contains(strings.ToLower(e.Search), strings.ToLower(*text)):
+			case *tag != "" && !e.HasTag(*tag):
+			case *status != "" && !strings.EqualFold(e.Status, *status):
+			case !after.IsZero() && e.SubmittedAt.Before(after):
+			default:
+				matched = append(matched, e)
+			}
+		}
+		if *limit > 0 && len(matched) > *limit {
+			matched = matched[len(matched)-*limit:]
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(tw, "ID\tSID\tSUBMITTED\tPROFILE\tSTATUS\tRESULTS\tRANGE\tTAGS\tSEARCH\n")
+		for _, e := range matched {
+			submitted := "-"
+			if !e.SubmittedAt.IsZero() {
+				submitted = e.SubmittedAt.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", e.ID, e.SearchID, submitted, e.Profile,
+				orDash(e.Status), e.ResultCount, timeRangeString(e), orDash(strings.Join(e.Tags, ",")), truncate(e.Search, *width))
+		}
+		return tw.Flush()
+	}
+	return c.add(historyShowCmd(a), historyRerunCmd(a), historyTagCmd(a))
+}
+
+// parseAge is time.ParseDuration that also accepts days, e.g. 7d
+func parseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days < 0 {
+			return 0, errors.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func timeRangeString(e splunk.HistoryEntry) string {
+	if e.Earliest == "" && e.Latest == "" {
+		return "-"
+	}
+	latest := e.Latest
+	if latest == "" {
+		latest = "now"
+	}
+	return orDash(e.Earliest) + ".." + latest
+}
+
+// findEntry looks up a history entry by its ID or search ID
+func findEntry(h *splunk.History, ref string) (splunk.HistoryEntry, error) {
+	entries, err := h.Entries()
+	if err != nil {
+		return splunk.HistoryEntry{}, err
+	}
+	id, _ := strconv.Atoi(ref)
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].SearchID == ref || (id > 0 && entries[i].ID == id) {
+			return entries[i], nil
+		}
+	}
+	return splunk.HistoryEntry{}, usageErrorf("no search %s in the history", ref)
+}
+
+func historyShowCmd(a *app) *command {
+	c := newCommand("show", "<id|sid>", "Print a search recorded in the history")
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "history ID or search ID"); err != nil {
+			return err
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		e, err := findEntry(cli.History, args[0])
+		if err != nil {
+			return err
+		}
+		return printJSON(e)
+	}
+	return c
+}
+
+func historyRerunCmd(a *app) *command {
+	c := newCommand("rerun", "<id|sid>", "Submit a search from the history again over the same time range, wait for it and print its results")
+	pageSize := c.flags.Int("page-size", 0, "number of results to fetch per request, 0 for the server's maximum")
+	format := outputFlag(c, output.JSON)
+	tags := tagsFlag(c)
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "history ID or search ID"); err != nil {
+			return err
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		e, err := findEntry(cli.History, args[0])
+		if err != nil {
+			return err
+		}
+		if *tags == "" {
+			*tags = strings.Join(e.Tags, ",")
+		}
+		// modifiers in the SPL itself take precedence over these
+		return runSearch(cli, e.Search, *tags, *pageSize, *format, splunk.WithTimeRange(e.Earliest, e.Latest))
+	}
+	return c
+}
+
+func historyTagCmd(a *app) *command {
+	c := newCommand("tag", "<id|sid> <tag>...", "Add tags to a search in the history")
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 2, "history ID or search ID and tags"); err != nil {
+			return err
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		e, err := findEntry(cli.History, args[0])
+		if err != nil {
+			return err
+		}
+		return cli.History.Tag(e.SearchID, args[1:]...)
+	}
+	return c
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jimmyjames85/splunkcli/pkg/splunk"
+	"github.com/pkg/errors"
+)
+
+func jobCmd(a *app) *command {
+	c := newCommand("job", "", "Control search jobs")
+	return c.add(
+		jobActionCmd(a, "cancel", "Stop search jobs and remove them from the server", (*splunk.Client).CancelJobContext),
+		jobActionCmd(a, "pause", "Suspend running search jobs", (*splunk.Client).PauseJobContext),
+		jobActionCmd(a, "resume", "Resume paused search jobs", (*splunk.Client).UnpauseJobContext),
+		jobActionCmd(a, "finalize", "Stop search jobs, keeping the results found so far", (*splunk.Client).FinalizeJobContext),
+		jobActionCmd(a, "touch", "Restart the time to live of search jobs", (*splunk.Client).TouchJobContext),
+		jobActionCmd(a, "delete", "Remove search jobs and their results from the server", (*splunk.Client).DeleteJobContext),
+		jobTTLCmd(a),
+	)
+}
+
+// jobActionCmd runs `action` on every search ID given
+func jobActionCmd(a *app, name, short string, action func(*splunk.Client, context.Context, string) error) *command {
+	c := newCommand(name, "<sid>...", short)
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "search ID"); err != nil {
+			return err
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := interruptContext()
+		defer cancel()
+		failed := 0
+		for _, sid := range args {
+			err := action(cli, ctx, sid)
+			if splunk.IsAuth(err) || ctx.Err() != nil {
+				return err
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", sid, err.Error())
+				failed++
+			}
+		}
+		if failed > 0 {
+			return errors.Errorf("%s failed for %d of %d jobs", name, failed, len(args))
+		}
+		return nil
+	}
+	return c
+}
+
+// parseTTL accepts seconds or a duration such as 2h
+func parseTTL(s string) (time.Duration, error) {
+	if sec, err := strconv.Atoi(s); err == nil {
+		return time.Duration(sec) * time.Second, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func jobTTLCmd(a *app) *command {
+	c := newCommand("ttl", "<sid> <ttl>", "Keep a search job and its results for <ttl> from now, in seconds or e.g. 24h")
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 2, "search ID and ttl"); err != nil {
+			return err
+		}
+		ttl, err := parseTTL(args[1])
+		if err != nil || ttl < time.Second {
+			return usageErrorf("invalid ttl %q: use seconds or a duration such as 24h", args[1])
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := interruptContext()
+		defer cancel()
+		return cli.SetJobTTLContext(ctx, args[0], ttl)
+	}
+	return c
+}
+
+// sortKeys maps the values of `splunk jobs --sort` to splunk's sort keys
+var sortKeys = map[string]string{"runtime": splunk.SortByRuntime, "disk": splunk.SortByDiskUsage}
+
+func jobsCmd(a *app) *command {
+	c := newCommand("jobs", "", "List the search jobs on the server, including those started elsewhere")
+	var f splunk.JobFilter
+	c.flags.StringVar(&f.Owner, "owner", "", "only jobs of this user")
+	c.flags.StringVar(&f.App, "app", "", "only jobs of this app")
+	c.flags.StringVar(&f.State, "state", "", "only jobs in this state, e.g. running, done, failed")
+	c.flags.StringVar(&f.Search, "search", "", "only jobs whose SPL contains this text")
+	sortBy := c.flags.String("sort", "", "sort by runtime or disk, largest first")
+	c.flags.IntVar(&f.Count, "limit", 50, "number of jobs to print, 0 for all")
+	c.flags.IntVar(&f.Offset, "offset", 0, "number of matching jobs to skip")
+	width := c.flags.Int("width", 60, "truncate the SPL to this many characters, 0 to print it all")
+	c.run = func(args []string) error {
+		if *sortBy != "" {
+			key, ok := sortKeys[*sortBy]
+			if !ok {
+				return usageErrorf("unknown sort %q: must be runtime or disk", *sortBy)
+			}
+			f.SortKey, f.SortDesc = key, true
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := interruptContext()
+		defer cancel()
+		jobs, err := cli.ListJobsContext(ctx, f)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(tw, "SID\tOWNER\tSTATE\tPROGRESS\tRUNTIME\tRESULTS\tSEARCH\n")
+		for _, j := range jobs {
+			runtime := time.Duration(j.RunDuration * float64(time.Second)).Round(time.Second)
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%.0f%%\t%s\t%d\t%s\n", j.SearchID, j.Owner, j.DispatchState,
+				j.DoneProgress*100, runtime, j.ResultCount, truncate(j.Search, *width))
+		}
+		return tw.Flush()
+	}
+	return c
+}
+
+// truncate puts `s` on one line of at most `width` characters
+func truncate(s string, width int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	r := []rune(s)
+	if width <= 0 || len(r) <= width {
+		return s
+	}
+	if width <= 3 {
+		return string(r[:width])
+	}
+	return string(r[:width-3]) + "..."
+}
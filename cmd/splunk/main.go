@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/howeyc/gopass"
 	"github.com/jimmyjames85/splunkcli/pkg/splunk"
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 func promptHidden(format string, args ...interface{}) string {
@@ -28,19 +34,30 @@ func prompt(format string, args ...interface{}) string {
 
 func exists(fileloc string) bool { _, err := os.Stat(fileloc); return !os.IsNotExist(err) }
 
-func DoInit(fileloc string) (*splunk.Client, error) {
+func DoInit(fileloc, profile string) (*splunk.Client, error) {
+	cfg, err := splunk.LoadConfig(fileloc)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if profile == "" {
+		profile = splunk.DefaultProfile
+		if cfg != nil && cfg.CurrentProfile != "" {
+			profile = cfg.CurrentProfile
+		}
+	}
 
-	if exists(fileloc) {
-		// prompt if file exists
-		resp := strings.ToLower(strings.TrimSpace(prompt("Init file already exists. Do you want to overwrite: ")))
+	if cfg != nil && cfg.Profiles[profile] != nil {
+		// prompt if profile exists
+		resp := strings.ToLower(strings.TrimSpace(prompt("Profile %q already exists. Do you want to overwrite: ", profile)))
 		if len(resp) == 0 || resp[0] != 'y' {
-			return nil, fmt.Errorf("user aborted")
+			return nil, errors.New("user aborted")
 		}
 	}
 
 	addr := prompt("Splunk Address: ") // https://localhost:8089
 	cli := splunk.New(addr)
-	_, err := DoCreateSessionID(cli)
+	cli.Profile = profile
+	_, err = DoCreateSessionID(cli)
 	if err != nil {
 		return nil, err
 	}
@@ -51,6 +68,49 @@ func DoInit(fileloc string) (*splunk.Client, error) {
 	return cli, nil
 }
 
+// DoLogin prompts for the credentials of `authType` and checks them
+// against the server. Basic auth keeps the password, in plaintext unless
+// the profile has a credential store
+func DoLogin(cli *splunk.Client, authType string) error {
+	switch authType {
+	case splunk.AuthToken:
+		cli.UseToken(promptHidden("token: "))
+	case splunk.AuthBasic:
+		user := prompt("username: ")
+		pass := promptHidden("password: ")
+		cli.UseBasicAuth(user, pass)
+	default:
+		_, err := DoCreateSessionID(cli)
+		return err
+	}
+	user, err := cli.CurrentUser()
+	if err != nil {
+		return errors.Wrapf(err, "unable to authenticate with %s", cli.Addr)
+	}
+	cli.Username = user
+	if authType == splunk.AuthBasic && cli.Store == "" {
+		splunk.Warnf("the password of profile %s is saved in plaintext in the config file, use --credential-store to keep it elsewhere", cli.Profile)
+	}
+	return nil
+}
+
+// authTypeFlags adds the flags selecting how a profile authenticates
+func authTypeFlags(c *command) func() (string, error) {
+	token := c.flags.Bool("token", false, "authenticate with a splunk authentication token")
+	basic := c.flags.Bool("basic", false, "authenticate every request with HTTP basic auth")
+	return func() (string, error) {
+		switch {
+		case *token && *basic:
+			return "", usageErrorf("--token and --basic are mutually exclusive")
+		case *token:
+			return splunk.AuthToken, nil
+		case *basic:
+			return splunk.AuthBasic, nil
+		}
+		return splunk.AuthSession, nil
+	}
+}
+
 func DoCreateSessionID(cli *splunk.Client) (string, error) {
 	user := prompt("username: ")
 	pass := promptHidden("password: ")
@@ -61,106 +121,232 @@ func DoCreateSessionID(cli *splunk.Client) (string, error) {
 	return sid, nil
 }
 
-func mustLoadClient(fileloc string) *splunk.Client {
-	var cli *splunk.Client
+// app holds the state shared by commands: the config file location,
+// the selected profile and the client loaded from it
+type app struct {
+	fileloc string
+	profile string
+	http    func(*splunk.HTTPOptions) bool // connection flags overriding the profile's
+	cli     *splunk.Client
+}
 
-	_, err := os.Stat(fileloc)
-	if os.IsNotExist(err) {
-		cli, err = DoInit(fileloc)
-	} else {
-		cli, err = splunk.LoadClient(fileloc)
-	}
+// client loads the selected profile from the config file on first
+// use, running init when the file does not exist yet
+func (a *app) client() (*splunk.Client, error) { return a.load(true) }
 
+// load is client, optionally without reading the profile's credential
+// store, for commands that replace the secrets anyway
+func (a *app) load(secrets bool) (*splunk.Client, error) {
+	if a.cli != nil {
+		return a.cli, nil
+	}
+	if !exists(a.fileloc) {
+		cli, err := DoInit(a.fileloc, a.profile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to create config file: %s", a.fileloc)
+		}
+		a.cli = cli
+		return cli, nil
+	}
+	cfg, err := a.config()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "unable to create config file: %s: %s\n", fileloc, err.Error())
-		os.Exit(-1)
+		return nil, err
+	}
+	cli, err := cfg.Lookup(a.profile)
+	if err != nil {
+		return nil, errors.Errorf("%s, run 'splunk profile list' to see the available profiles", err)
+	}
+	if secrets {
+		cli, err = cfg.Client(a.profile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := a.overrideHTTP(cli); err != nil {
+		return nil, err
 	}
-	return cli
+	cli.OnRetry = printRetry
+	cli.Reauth = splunk.ReauthWith(
+		func(c *splunk.Client) error { return c.SaveTo(a.fileloc) },
+		splunk.EnvCredentials,
+		splunk.StoreCredentials,
+		promptCredentials,
+	)
+	a.cli = cli
+	return cli, nil
 }
 
-func printHelp() {
-	fmt.Printf("help - TODO\n")
+// overrideHTTP applies the connection flags given to the root command
+// for this run only, leaving the profile's saved settings untouched
+func (a *app) overrideHTTP(cli *splunk.Client) error {
+	if a.http == nil {
+		return nil
+	}
+	var o splunk.HTTPOptions
+	if cli.HTTP != nil {
+		o = *cli.HTTP
+	}
+	if !a.http(&o) {
+		return nil
+	}
+	h, err := splunk.NewHTTPClient(cli.TLS, &o)
+	if err != nil {
+		return usageErrorf("%s", err.Error())
+	}
+	splunk.WithHTTPClient(h)(cli)
+	return nil
 }
 
-func exitf(code int, format string, a ...interface{}) {
-	fmt.Fprintf(os.Stderr, format, a...)
-	os.Exit(code)
+// printRetry tells the user why a request is being retried
+func printRetry(ev splunk.RetryEvent) {
+	delay := ev.Delay.Round(time.Millisecond)
+	if ev.Err != nil {
+		fmt.Fprintf(os.Stderr, "%s, retrying in %s\n", ev.Err.Error(), delay)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s %s: %d %s, retrying in %s\n", ev.Method, ev.Path, ev.StatusCode, http.StatusText(ev.StatusCode), delay)
 }
 
-// panic if error -- to be removed
-func mustBeNil(err error) {
+// promptCredentials asks for credentials on the terminal when the
+// session expires in the middle of a command
+func promptCredentials(cli *splunk.Client) (string, string, error) {
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return "", "", splunk.ErrCredentialNotFound
+	}
+	fmt.Fprintf(os.Stderr, "session for %s expired\nusername [%s]: ", cli.Addr, cli.Username)
+	var user string
+	fmt.Scanln(&user) // ignores error
+	if user == "" {
+		user = cli.Username
+	}
+	fmt.Fprintf(os.Stderr, "password: ")
+	pass, err := gopass.GetPasswd()
 	if err != nil {
-		panic(err)
+		return "", "", err
 	}
+	return user, string(pass), nil
 }
 
-func main() {
-	////////// load config location
-	fileloc := fmt.Sprintf("%s/.splunk", os.Getenv("HOME"))
-	cli := mustLoadClient(fileloc)
-
-	if len(os.Args) < 2 {
-		printHelp()
-		exitf(-1, "Please provide an argument\n")
+func (a *app) config() (*splunk.Config, error) {
+	cfg, err := splunk.LoadConfig(a.fileloc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to load config file: %s", a.fileloc)
 	}
+	return cfg, nil
+}
+
+func (a *app) save() error { return a.cli.SaveTo(a.fileloc) }
 
-	command := os.Args[1]
-	switch command {
-	case "init":
-		_, err := DoInit(fileloc)
+func initCmd(a *app) *command {
+	c := newCommand("init", "", "Set up the selected profile by logging in to a splunk instance")
+	c.run = func(args []string) error {
+		cli, err := DoInit(a.fileloc, a.profile)
 		if err != nil {
-			exitf(-1, "%s\n", err.Error())
-		}
-		return
-	case "login":
-		_, err := DoCreateSessionID(cli)
-		mustBeNil(err)
-		err = cli.SaveTo(fileloc)
-		mustBeNil(err)
-	case "search":
-		if len(os.Args) < 3 {
-			exitf(-1, "Please provide search\n")
+			return err
 		}
-		search := os.Args[2] // fmt.Sprintf("search earliest=-1h host=*filter* event=FilterReceived OR event=processed OR event=drop")
+		a.cli = cli
+		return nil
+	}
+	return c
+}
 
-		if strings.Index(strings.ToLower(search), "earliest") == -1 {
-			fmt.Printf("%s\n", search)
-			exitf(-1, "please specify time range: TODO get url or documentation\n")
+const credentialStoreUsage = "where to keep secrets: config, file, encrypted or helper:<name>"
+
+func loginCmd(a *app) *command {
+	c := newCommand("login", "", "Create a new session, e.g. after the current one expired, or switch to token or basic auth")
+	store := c.flags.String("credential-store", "", credentialStoreUsage)
+	authType := authTypeFlags(c)
+	c.run = func(args []string) error {
+		auth, err := authType()
+		if err != nil {
+			return err
 		}
-		r, err := cli.Search(search)
-		mustBeNil(err)
-		fmt.Printf("{\"searchID\": %q}\n", r.SearchID)
-		cli.SaveTo(fileloc)
-	case "clear":
-
-		err := cli.ClearKnownSearches()
-		mustBeNil(err)
-		cli.SaveTo(fileloc)
-	case "status":
-		if len(os.Args) < 3 {
-			exitf(-1, "Please provide search ID\n")
+		cli, err := a.load(false)
+		if err != nil {
+			return err
 		}
-		sid := os.Args[2]
-		r, err := cli.GetSearchStatus(sid)
-		if r.AuthFailed() {
-			exitf(-1, "auth failed: perhaps session expired")
+		if *store != "" {
+			if _, err := splunk.OpenCredentialStore(*store, a.fileloc); err != nil {
+				return usageErrorf("%s", err.Error())
+			}
+			cli.UseCredentialStore(*store)
 		}
-		mustBeNil(err)
-		fmt.Printf("%s\n", string(r.Body))
-	case "results":
-		if len(os.Args) < 3 {
-			exitf(-1, "Please provide search ID\n")
+		err = DoLogin(cli, auth)
+		if err != nil {
+			return err
 		}
-		sid := os.Args[2]
-		r, err := cli.GetSearchResults(sid, splunk.WithParam("count", "0")) // 0 means get all results https://docs.splunk.com/Documentation/Splunk/7.2.3/RESTREF/RESTsearch#search.2Fjobs.2F.7Bsearch_id.7D.2Fresults
-		if r.AuthFailed() {
-			exitf(-1, "auth failed: perhaps session expired")
+		return a.save()
+	}
+	return c
+}
+
+func rootCmd(a *app) *command {
+	root := newCommand("splunk", "", "A command line utility for searching splunk using the splunk API.")
+	root.flags.StringVar(&a.fileloc, "config", fmt.Sprintf("%s/.splunk", os.Getenv("HOME")), "config file location")
+	root.flags.StringVar(&a.profile, "profile", os.Getenv("SPLUNK_PROFILE"), "profile to use instead of the current one, also set by SPLUNK_PROFILE")
+	a.http = httpFlags(root.flags)
+	root.add(
+		initCmd(a),
+		loginCmd(a),
+		searchCmd(a),
+		runCmd(a),
+		exportCmd(a),
+		statusCmd(a),
+		waitCmd(a),
+		resultsCmd(a),
+		clearCmd(a),
+		historyCmd(a),
+		jobCmd(a),
+		jobsCmd(a),
+		savedCmd(a),
+		sendCmd(a),
+		profileCmd(a),
+		tokenCmd(a),
+		completionCmd(root),
+	)
+	root.add(helpCmd(root))
+	return root
+}
+
+// interruptContext returns a context that is cancelled on the first
+// interrupt. A second interrupt exits immediately
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(sig)
+			return
 		}
-		mustBeNil(err)
-		fmt.Printf("%s\n", string(r.Body))
-	default:
-		printHelp()
-		exitf(-1, "unkown cmd: %s", command)
-		os.Exit(-1)
+		<-sig
+		os.Exit(exitInterrupted)
+	}()
+	return ctx, cancel
+}
+
+// promptPassphrase asks for the passphrase of an encrypted credential
+// store unless SPLUNK_PASSPHRASE is set
+func promptPassphrase() (string, error) {
+	if p := os.Getenv("SPLUNK_PASSPHRASE"); p != "" {
+		return p, nil
 	}
+	fmt.Fprintf(os.Stderr, "credential store passphrase: ")
+	answer, err := gopass.GetPasswd()
+	if err != nil {
+		return "", err
+	}
+	return string(answer), nil
+}
+
+func main() {
+	splunk.PassphraseFunc = promptPassphrase
+	code, msg := exitCode(rootCmd(&app{}).execute(os.Args[1:]))
+	if msg != "" {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+	os.Exit(code)
 }
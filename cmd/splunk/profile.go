@@ -0,0 +1,338 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jimmyjames85/splunkcli/pkg/splunk"
+	"github.com/pkg/errors"
+)
+
+func profileCmd(a *app) *command {
+	c := newCommand("profile", "", "Manage named profiles for multiple splunk deployments")
+	c.run = func(args []string) error {
+		cfg, err := a.config()
+		if err != nil {
+			return err
+		}
+		fmt.Println(cfg.CurrentProfile)
+		return nil
+	}
+	return c.add(
+		profileListCmd(a),
+		profileAddCmd(a),
+		profileSetCmd(a),
+		profileUseCmd(a),
+		profileRemoveCmd(a),
+	)
+}
+
+func profileListCmd(a *app) *command {
+	c := newCommand("list", "", "List profiles, marking the current one with *")
+	c.run = func(args []string) error {
+		cfg, err := a.config()
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(tw, "\tPROFILE\tADDR\tUSERNAME\tAUTH\tCREDENTIALS\n")
+		for _, name := range cfg.ProfileNames() {
+			current := ""
+			if name == cfg.CurrentProfile {
+				current = "*"
+			}
+			cli := cfg.Profiles[name]
+			store := cli.Store
+			if store == "" {
+				store = "config"
+			}
+			auth := cli.AuthType
+			if auth == "" {
+				auth = splunk.AuthSession
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", current, name, cli.Addr, cli.Username, auth, store)
+		}
+		return tw.Flush()
+	}
+	return c
+}
+
+func profileAddCmd(a *app) *command {
+	c := newCommand("add", "<name>", "Add a profile and log in to it")
+	addr := c.flags.String("addr", "", "splunk management address, e.g. https://localhost:8089")
+	use := c.flags.Bool("use", false, "make the new profile the current one")
+	store := c.flags.String("credential-store", "", credentialStoreUsage)
+	authType := authTypeFlags(c)
+	applyTLS := tlsFlags(c)
+	conn := httpFlags(c.flags)
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "profile name"); err != nil {
+			return err
+		}
+		auth, err := authType()
+		if err != nil {
+			return err
+		}
+		name := args[0]
+		if exists(a.fileloc) {
+			cfg, err := a.config()
+			if err != nil {
+				return err
+			}
+			if _, ok := cfg.Profiles[name]; ok {
+				return errors.Errorf("profile %s already exists", name)
+			}
+		}
+		if *addr == "" {
+			*addr = prompt("Splunk Address: ")
+		}
+		cli := splunk.New(*addr)
+		cli.Profile = name
+		if *store != "" {
+			if _, err := splunk.OpenCredentialStore(*store, a.fileloc); err != nil {
+				return usageErrorf("%s", err.Error())
+			}
+			cli.UseCredentialStore(*store)
+		}
+		if err := applyTLS(cli); err != nil {
+			return err
+		}
+		if err := applyHTTP(cli, conn); err != nil {
+			return err
+		}
+		if err := DoLogin(cli, auth); err != nil {
+			return err
+		}
+		if err := cli.SaveTo(a.fileloc); err != nil {
+			return err
+		}
+		if !*use {
+			return nil
+		}
+		return setCurrentProfile(a, name)
+	}
+	return c
+}
+
+// tlsFlags adds the flags for a profile's TLS settings. The returned
+// func applies the flags that were given to cli
+func tlsFlags(c *command) func(cli *splunk.Client) error {
+	var t splunk.TLSOptions
+	c.flags.StringVar(&t.CAFile, "ca-file", "", "PEM bundle of CAs to trust in addition to the system's")
+	c.flags.StringVar(&t.CertFile, "cert", "", "PEM client certificate for mTLS")
+	c.flags.StringVar(&t.KeyFile, "key", "", "PEM key of the client certificate")
+	c.flags.StringVar(&t.ServerName, "server-name", "", "name to verify the server certificate against")
+	c.flags.BoolVar(&t.InsecureSkipVerify, "insecure", false, "do not verify the server certificate")
+	return func(cli *splunk.Client) error {
+		var cur splunk.TLSOptions
+		if cli.TLS != nil {
+			cur = *cli.TLS
+		}
+		var err error
+		abs := func(path string) string {
+			if path == "" || err != nil {
+				return path
+			}
+			path, err = filepath.Abs(path)
+			return path
+		}
+		c.flags.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "ca-file":
+				cur.CAFile = abs(t.CAFile)
+			case "cert":
+				cur.CertFile = abs(t.CertFile)
+			case "key":
+				cur.KeyFile = abs(t.KeyFile)
+			case "server-name":
+				cur.ServerName = t.ServerName
+			case "insecure":
+				cur.InsecureSkipVerify = t.InsecureSkipVerify
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if cur == (splunk.TLSOptions{}) {
+			cli.TLS = nil
+			return nil
+		}
+		if _, err := cur.Config(); err != nil {
+			return usageErrorf("%s", err.Error())
+		}
+		cli.TLS = &cur
+		return nil
+	}
+}
+
+// httpFlags adds the flags for proxying, timeouts and keep-alives. The
+// returned func applies the flags that were given to o and reports
+// whether there were any
+func httpFlags(fs *flag.FlagSet) func(o *splunk.HTTPOptions) bool {
+	var o splunk.HTTPOptions
+	var timeout, dial, header, keepAlive, idle time.Duration
+	fs.StringVar(&o.Proxy, "proxy", "", "HTTP(S) proxy URL, \"direct\" for none; defaults to HTTPS_PROXY")
+	fs.DurationVar(&timeout, "timeout", 0, "overall timeout of a request, exports excepted")
+	fs.DurationVar(&dial, "dial-timeout", 0, "timeout for connecting to splunk")
+	fs.DurationVar(&header, "response-header-timeout", 0, "timeout for splunk to start responding")
+	fs.DurationVar(&keepAlive, "keep-alive", 0, "TCP keep-alive period, negative disables it")
+	fs.DurationVar(&idle, "idle-conn-timeout", 0, "how long idle connections are kept for reuse")
+	fs.IntVar(&o.MaxIdleConnsPerHost, "max-idle-conns", 0, "number of idle connections kept for reuse")
+	fs.BoolVar(&o.DisableKeepAlives, "disable-keep-alives", false, "use a new connection for every request")
+	return func(cur *splunk.HTTPOptions) bool {
+		set := false
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "proxy":
+				cur.Proxy = o.Proxy
+			case "timeout":
+				cur.Timeout = splunk.Duration(timeout)
+			case "dial-timeout":
+				cur.DialTimeout = splunk.Duration(dial)
+			case "response-header-timeout":
+				cur.ResponseHeaderTimeout = splunk.Duration(header)
+			case "keep-alive":
+				cur.KeepAlive = splunk.Duration(keepAlive)
+			case "idle-conn-timeout":
+				cur.IdleConnTimeout = splunk.Duration(idle)
+			case "max-idle-conns":
+				cur.MaxIdleConnsPerHost = o.MaxIdleConnsPerHost
+			case "disable-keep-alives":
+				cur.DisableKeepAlives = o.DisableKeepAlives
+			default:
+				return
+			}
+			set = true
+		})
+		return set
+	}
+}
+
+// applyHTTP sets the connection settings given by flags on cli
+func applyHTTP(cli *splunk.Client, flags func(*splunk.HTTPOptions) bool) error {
+	var cur splunk.HTTPOptions
+	if cli.HTTP != nil {
+		cur = *cli.HTTP
+	}
+	if !flags(&cur) {
+		return nil
+	}
+	if cur == (splunk.HTTPOptions{}) {
+		cli.HTTP = nil
+		return nil
+	}
+	if _, err := splunk.NewHTTPClient(nil, &cur); err != nil {
+		return usageErrorf("%s", err.Error())
+	}
+	cli.HTTP = &cur
+	return nil
+}
+
+// retryFlags adds the flags for a profile's retry policy. The returned
+// func applies the flags that were given to cli
+func retryFlags(fs *flag.FlagSet) func(cli *splunk.Client) {
+	attempts := fs.Int("retries", 0, "attempts per request, including the first, 1 disables retries")
+	minBackoff := fs.Duration("retry-min-backoff", 0, "delay before the first retry")
+	maxBackoff := fs.Duration("retry-max-backoff", 0, "longest delay between retries")
+	return func(cli *splunk.Client) {
+		var p splunk.RetryPolicy
+		if cli.Retry != nil {
+			p = *cli.Retry
+		}
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "retries":
+				p.MaxAttempts = *attempts
+			case "retry-min-backoff":
+				p.MinBackoff = splunk.Duration(*minBackoff)
+			case "retry-max-backoff":
+				p.MaxBackoff = splunk.Duration(*maxBackoff)
+			}
+		})
+		if p == (splunk.RetryPolicy{}) {
+			cli.Retry = nil
+			return
+		}
+		cli.Retry = &p
+	}
+}
+
+func profileSetCmd(a *app) *command {
+	c := newCommand("set", "<name>", "Change the address, TLS, connection or retry settings of a profile")
+	addr := c.flags.String("addr", "", "splunk management address, e.g. https://localhost:8089")
+	applyTLS := tlsFlags(c)
+	conn := httpFlags(c.flags)
+	applyRetry := retryFlags(c.flags)
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "profile name"); err != nil {
+			return err
+		}
+		cfg, err := a.config()
+		if err != nil {
+			return err
+		}
+		cli, err := cfg.Lookup(args[0])
+		if err != nil {
+			return err
+		}
+		if *addr != "" {
+			cli.Addr = *addr
+		}
+		if err := applyTLS(cli); err != nil {
+			return err
+		}
+		if err := applyHTTP(cli, conn); err != nil {
+			return err
+		}
+		applyRetry(cli)
+		return cfg.SaveTo(a.fileloc)
+	}
+	return c
+}
+
+func profileUseCmd(a *app) *command {
+	c := newCommand("use", "<name>", "Make a profile the current one")
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "profile name"); err != nil {
+			return err
+		}
+		return setCurrentProfile(a, args[0])
+	}
+	return c
+}
+
+func profileRemoveCmd(a *app) *command {
+	c := newCommand("remove", "<name>", "Remove a profile")
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "profile name"); err != nil {
+			return err
+		}
+		cfg, err := a.config()
+		if err != nil {
+			return err
+		}
+		if err := cfg.Remove(args[0]); err != nil {
+			return err
+		}
+		if cfg.CurrentProfile == "" && len(cfg.Profiles) > 0 {
+			fmt.Fprintf(os.Stderr, "removed the current profile, select another with 'splunk profile use'\n")
+		}
+		return cfg.SaveTo(a.fileloc)
+	}
+	return c
+}
+
+func setCurrentProfile(a *app, name string) error {
+	cfg, err := a.config()
+	if err != nil {
+		return err
+	}
+	if err := cfg.Use(name); err != nil {
+		return err
+	}
+	return cfg.SaveTo(a.fileloc)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jimmyjames85/splunkcli/pkg/splunk"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const progressWidth = 30
+
+// progressBar draws job progress on a single, rewritten line. Nothing
+// is drawn when the output is not a terminal
+type progressBar struct {
+	out     io.Writer
+	enabled bool
+	drawn   bool
+}
+
+func newProgressBar(f *os.File) *progressBar {
+	return &progressBar{out: f, enabled: terminal.IsTerminal(int(f.Fd()))}
+}
+
+func (p *progressBar) Update(s splunk.JobStatus) {
+	if !p.enabled {
+		return
+	}
+	filled := int(s.DoneProgress * progressWidth)
+	if filled > progressWidth {
+		filled = progressWidth
+	}
+	bar := strings.Repeat("#", filled) + strings.Repeat(" ", progressWidth-filled)
+	fmt.Fprintf(p.out, "\r[%s] %5.1f%% %-10s scanned: %d results: %d", bar, s.DoneProgress*100, s.DispatchState, s.ScanCount, s.ResultCount)
+	p.drawn = true
+}
+
+// Done moves past the progress line so later output starts on a fresh line
+func (p *progressBar) Done() {
+	if p.drawn {
+		fmt.Fprintln(p.out)
+		p.drawn = false
+	}
+}
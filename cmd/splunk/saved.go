@@ -0,0 +1,346 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jimmyjames85/splunkcli/pkg/splunk"
+	"github.com/pkg/errors"
+)
+
+func savedCmd(a *app) *command {
+	c := newCommand("saved", "", "Manage saved searches and alerts")
+	return c.add(
+		savedListCmd(a),
+		savedShowCmd(a),
+		savedCreateCmd(a),
+		savedUpdateCmd(a),
+		savedDeleteCmd(a),
+		savedDispatchCmd(a),
+		savedHistoryCmd(a),
+		savedApplyCmd(a),
+		savedExportCmd(a),
+	)
+}
+
+// namespaceFlags adds --app and --owner
+func namespaceFlags(c *command) *splunk.Namespace {
+	var ns splunk.Namespace
+	c.flags.StringVar(&ns.App, "app", "", "app of the saved search, any app if empty")
+	c.flags.StringVar(&ns.Owner, "owner", "", "owner of the saved search, any owner if empty")
+	return &ns
+}
+
+// paramsValue collects key=value flags given more than once
+type paramsValue map[string]string
+
+func (p paramsValue) String() string {
+	var ret []string
+	for k, v := range p {
+		ret = append(ret, k+"="+v)
+	}
+	sort.Strings(ret)
+	return strings.Join(ret, ",")
+}
+
+func (p paramsValue) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return errors.New("expected key=value")
+	}
+	p[s[:i]] = s[i+1:]
+	return nil
+}
+
+// savedFlags adds the flags for the attributes of a saved search. The
+// returned function applies those that were given
+func savedFlags(c *command) func(s *splunk.SavedSearch) error {
+	fs := c.flags
+	var s splunk.SavedSearch
+	var a splunk.Alert
+	var actions string
+	params := paramsValue{}
+	fs.StringVar(&s.Description, "description", "", "description of the saved search")
+	fs.BoolVar(&s.Disabled, "disabled", false, "disable the saved search")
+	fs.StringVar(&s.CronSchedule, "cron", "", "run on this cron schedule, e.g. '*/15 * * * *', empty to unschedule")
+	fs.StringVar(&s.Earliest, "earliest", "", "start of the time range it is dispatched with, e.g. -15m")
+	fs.StringVar(&s.Latest, "latest", "", "end of the time range it is dispatched with, e.g. now")
+	fs.StringVar(&a.Type, "alert-type", "", "trigger: always, number of events, number of results, number of hosts, number of sources or custom")
+	fs.StringVar(&a.Comparator, "alert-comparator", "", "e.g. greater than, less than, equal to, rises by, drops by")
+	fs.StringVar(&a.Threshold, "alert-threshold", "", "the number the comparator is applied to")
+	fs.StringVar(&a.Condition, "alert-condition", "", "search over the results that triggers a custom alert")
+	fs.IntVar(&a.Severity, "severity", 0, "alert severity, 1 debug to 6 fatal")
+	fs.StringVar(&a.Suppress, "suppress", "", "throttle the alert for this period after it triggers, e.g. 1h")
+	fs.StringVar(&actions, "actions", "", "comma separated alert actions, e.g. email,webhook")
+	fs.Var(params, "param", "set any other attribute, e.g. action.email.to=ops@example.com; repeatable")
+	return func(cur *splunk.SavedSearch) error {
+		alert := func() *splunk.Alert {
+			if cur.Alert == nil {
+				cur.Alert = &splunk.Alert{}
+			}
+			return cur.Alert
+		}
+		var err error
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "description":
+				cur.Description = s.Description
+			case "disabled":
+				cur.Disabled = s.Disabled
+			case "cron":
+				cur.CronSchedule = s.CronSchedule
+			case "earliest":
+				cur.Earliest = s.Earliest
+				err = validateTime(s.Earliest, err)
+			case "latest":
+				cur.Latest = s.Latest
+				err = validateTime(s.Latest, err)
+			case "alert-type":
+				alert().Type = a.Type
+			case "alert-comparator":
+				alert().Comparator = a.Comparator
+			case "alert-threshold":
+				alert().Threshold = a.Threshold
+			case "alert-condition":
+				alert().Condition = a.Condition
+			case "severity":
+				alert().Severity = a.Severity
+			case "suppress":
+				alert().Suppress = a.Suppress
+			case "actions":
+				alert().Actions = splitTags(actions)
+			case "param":
+				if cur.Params == nil {
+					cur.Params = map[string]string{}
+				}
+				for k, v := range params {
+					cur.Params[k] = v
+				}
+			}
+		})
+		return err
+	}
+}
+
+// validateTime returns `err` if set, or else a usage error if `t` is
+// not a valid splunk time
+func validateTime(t string, err error) error {
+	if err != nil || t == "" {
+		return err
+	}
+	if verr := splunk.ValidateTime(t); verr != nil {
+		return usageErrorf("%s", verr.Error())
+	}
+	return nil
+}
+
+func savedListCmd(a *app) *command {
+	c := newCommand("list", "", "List saved searches")
+	ns := namespaceFlags(c)
+	text := c.flags.String("search", "", "only saved searches whose name or SPL contains this text")
+	width := c.flags.Int("width", 60, "truncate the SPL to this many characters, 0 to print it all")
+	c.run = func(args []string) error {
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := interruptContext()
+		defer cancel()
+		saved, err := cli.ListSavedSearchesContext(ctx, *ns)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(tw, "NAME\tAPP\tOWNER\tSCHEDULE\tACTIONS\tDISABLED\tSEARCH\n")
+		for _, s := range saved {
+			lower := strings.ToLower(*text)
+			if *text != "" && !strings.Contains(strings.ToLower(s.Name), lower) && !strings.Contains(strings.ToLower(s.Search), lower) {
+				continue
+			}
+			var actions []string
+			if s.Alert != nil {
+				actions = s.Alert.Actions
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%t\t%s\n", s.Name, s.App, s.Owner, orDash(s.CronSchedule),
+				orDash(strings.Join(actions, ",")), s.Disabled, truncate(s.Search, *width))
+		}
+		return tw.Flush()
+	}
+	return c
+}
+
+func savedShowCmd(a *app) *command {
+	c := newCommand("show", "<name>", "Print a saved search")
+	ns := namespaceFlags(c)
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "saved search name"); err != nil {
+			return err
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := interruptContext()
+		defer cancel()
+		s, err := cli.GetSavedSearchContext(ctx, *ns, args[0])
+		if err != nil {
+			return err
+		}
+		return printJSON(s)
+	}
+	return c
+}
+
+func savedCreateCmd(a *app) *command {
+	c := newCommand("create", "<name> <spl>", "Create a saved search, shared in its app if --app is given without --owner")
+	ns := namespaceFlags(c)
+	apply := savedFlags(c)
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 2, "saved search name and search"); err != nil {
+			return err
+		}
+		s := splunk.SavedSearch{Name: args[0], Search: args[1], App: ns.App, Owner: ns.Owner}
+		if err := apply(&s); err != nil {
+			return err
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := interruptContext()
+		defer cancel()
+		return cli.CreateSavedSearchContext(ctx, s)
+	}
+	return c
+}
+
+func savedUpdateCmd(a *app) *command {
+	c := newCommand("update", "<name> [<spl>]", "Change the search or the attributes given by flags of a saved search")
+	ns := namespaceFlags(c)
+	apply := savedFlags(c)
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "saved search name"); err != nil {
+			return err
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := interruptContext()
+		defer cancel()
+		s, err := cli.GetSavedSearchContext(ctx, *ns, args[0])
+		if err != nil {
+			return err
+		}
+		if len(args) > 1 {
+			s.Search = args[1]
+		}
+		if err := apply(&s); err != nil {
+			return err
+		}
+		return cli.UpdateSavedSearchContext(ctx, s)
+	}
+	return c
+}
+
+func savedDeleteCmd(a *app) *command {
+	c := newCommand("delete", "<name>", "Delete a saved search")
+	ns := namespaceFlags(c)
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "saved search name"); err != nil {
+			return err
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := interruptContext()
+		defer cancel()
+		s, err := cli.GetSavedSearchContext(ctx, *ns, args[0])
+		if err != nil {
+			return err
+		}
+		return cli.DeleteSavedSearchContext(ctx, s.Namespace(), s.Name)
+	}
+	return c
+}
+
+func savedDispatchCmd(a *app) *command {
+	c := newCommand("dispatch", "<name>", "Run a saved search now and print the search ID of its job")
+	ns := namespaceFlags(c)
+	earliest := c.flags.String("earliest", "", "override the start of its time range, e.g. -4h@h or 2026-10-01T00:00")
+	latest := c.flags.String("latest", "", "override the end of its time range")
+	trigger := c.flags.Bool("trigger-actions", false, "run its alert actions if the alert condition is met")
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "saved search name"); err != nil {
+			return err
+		}
+		var err error
+		for _, t := range []*string{earliest, latest} {
+			if *t == "" {
+				continue
+			}
+			if *t, err = splunk.NormalizeTime(*t); err != nil {
+				return usageErrorf("%s", err.Error())
+			}
+		}
+		opts := []splunk.Option{splunk.WithTimeRange(*earliest, *latest)}
+		if *trigger {
+			opts = append(opts, splunk.WithParam("trigger_actions", "1"))
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := interruptContext()
+		defer cancel()
+		s, err := cli.GetSavedSearchContext(ctx, *ns, args[0])
+		if err != nil {
+			return err
+		}
+		sid, err := cli.DispatchSavedSearchContext(ctx, s.Namespace(), s.Name, opts...)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("{\"searchID\": %q}\n", sid)
+		return nil
+	}
+	return c
+}
+
+func savedHistoryCmd(a *app) *command {
+	c := newCommand("history", "<name>", "List the jobs of a saved search still on the server")
+	ns := namespaceFlags(c)
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "saved search name"); err != nil {
+			return err
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := interruptContext()
+		defer cancel()
+		s, err := cli.GetSavedSearchContext(ctx, *ns, args[0])
+		if err != nil {
+			return err
+		}
+		jobs, err := cli.SavedSearchHistoryContext(ctx, s.Namespace(), s.Name)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(tw, "SID\tSTATE\tRUNTIME\tRESULTS\n")
+		for _, j := range jobs {
+			runtime := time.Duration(j.RunDuration * float64(time.Second)).Round(time.Second)
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", j.SearchID, orDash(j.DispatchState), runtime, j.ResultCount)
+		}
+		return tw.Flush()
+	}
+	return c
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jimmyjames85/splunkcli/pkg/splunk"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/yaml.v2"
+)
+
+// definitionExts are the extensions of saved search definition files
+var definitionExts = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+// loadDefinitions reads the saved search definitions in the file at
+// `path` or in the files under it. Definitions without an app get `app`
+func loadDefinitions(path, app string) ([]splunk.SavedSearch, error) {
+	var ret []splunk.SavedSearch
+	err := filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if p != path && strings.HasPrefix(fi.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if p != path && !definitionExts[strings.ToLower(filepath.Ext(p))] {
+			return nil
+		}
+		defs, err := readDefinitions(p)
+		if err != nil {
+			return errors.Wrap(err, p)
+		}
+		for _, d := range defs {
+			if d.App == "" {
+				d.App = app
+			}
+			if err := checkDefinition(d); err != nil {
+				return errors.Wrap(err, p)
+			}
+			ret = append(ret, d)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// readDefinitions decodes a file holding one definition or a list of
+// them, rejecting unknown attributes
+func readDefinitions(path string) ([]splunk.SavedSearch, error) {
+	byts, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	list := bytes.HasPrefix(bytes.TrimSpace(byts), []byte("["))
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		dec := json.NewDecoder(bytes.NewReader(byts))
+		dec.DisallowUnknownFields()
+		if list {
+			var ret []splunk.SavedSearch
+			return ret, dec.Decode(&ret)
+		}
+		var s splunk.SavedSearch
+		return []splunk.SavedSearch{s}, dec.Decode(&s)
+	}
+	var probe interface{}
+	if err := yaml.Unmarshal(byts, &probe); err != nil {
+		return nil, err
+	}
+	if _, ok := probe.([]interface{}); ok {
+		var ret []splunk.SavedSearch
+		return ret, yaml.UnmarshalStrict(byts, &ret)
+	}
+	var s splunk.SavedSearch
+	return []splunk.SavedSearch{s}, yaml.UnmarshalStrict(byts, &s)
+}
+
+func checkDefinition(s splunk.SavedSearch) error {
+	switch {
+	case s.Name == "":
+		return errors.New("saved search without a name")
+	case s.Search == "":
+		return errors.Errorf("saved search %q has no search", s.Name)
+	case s.App == "":
+		return errors.Errorf("saved search %q has no app, set app: or use --app", s.Name)
+	}
+	for _, t := range []string{s.Earliest, s.Latest} {
+		if t == "" {
+			continue
+		}
+		if err := splunk.ValidateTime(t); err != nil {
+			return errors.Wrapf(err, "saved search %q", s.Name)
+		}
+	}
+	return nil
+}
+
+// palette colors the plan, or not
+type palette struct{ on bool }
+
+func (p palette) color(code, s string) string {
+	if !p.on {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+func (p palette) green(s string) string  { return p.color("32", s) }
+func (p palette) yellow(s string) string { return p.color("33", s) }
+func (p palette) red(s string) string    { return p.color("31", s) }
+
+// printPlan prints the steps that change something and a summary, and
+// returns the number of changes
+func printPlan(w io.Writer, steps []splunk.PlanStep, p palette) int {
+	counts := map[string]int{}
+	for _, s := range steps {
+		counts[s.Action]++
+		switch s.Action {
+		case splunk.PlanCreate:
+			fmt.Fprintf(w, "%s\n", p.green("+ create "+s.Want.App+"/"+s.Want.Name))
+			attrs := s.Want.Attrs()
+			var names []string
+			for k, v := range attrs {
+				if v != "" && v != "false" && !(k == "alert_type" && v == "always") {
+					names = append(names, k)
+				}
+			}
+			sort.Strings(names)
+			for _, k := range names {
+				fmt.Fprintf(w, "    %s: %s\n", k, p.green(fmt.Sprintf("%q", attrs[k])))
+			}
+		case splunk.PlanUpdate:
+			fmt.Fprintf(w, "%s\n", p.yellow("~ update "+s.Have.App+"/"+s.Have.Name))
+			for _, c := range s.Changes {
+				fmt.Fprintf(w, "    %s: %s -> %s\n", c.Name, p.red(fmt.Sprintf("%q", c.Old)), p.green(fmt.Sprintf("%q", c.New)))
+			}
+		case splunk.PlanDelete:
+			fmt.Fprintf(w, "%s\n", p.red("- delete "+s.Have.App+"/"+s.Have.Name))
+		}
+	}
+	fmt.Fprintf(w, "Plan: %d to create, %d to update, %d to delete, %d unchanged.\n",
+		counts[splunk.PlanCreate], counts[splunk.PlanUpdate], counts[splunk.PlanDelete], counts[splunk.PlanKeep])
+	return len(steps) - counts[splunk.PlanKeep]
+}
+
+// confirm asks whether to go ahead, on a terminal only
+func confirm(question string) (bool, error) {
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return false, usageErrorf("stdin is not a terminal: use --yes to apply without confirmation")
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
+
+// applied describes a step once done
+var applied = map[string]string{splunk.PlanCreate: "created", splunk.PlanUpdate: "updated", splunk.PlanDelete: "deleted"}
+
+func savedApplyCmd(a *app) *command {
+	c := newCommand("apply", "", "Make the saved searches on the server match YAML or JSON definitions")
+	path := c.flags.String("f", "", "definition file, or directory of .yaml, .yml and .json files")
+	app := c.flags.String("app", "", "app of definitions that do not name one")
+	prune := c.flags.Bool("prune", false, "delete saved searches in the owners and apps of the definitions that no definition mentions")
+	dryRun := c.flags.Bool("dry-run", false, "print the plan without changing anything")
+	yes := c.flags.Bool("yes", false, "apply the plan without asking for confirmation")
+	noColor := c.flags.Bool("no-color", false, "do not color the plan")
+	c.run = func(args []string) error {
+		if *path == "" {
+			return usageErrorf("please provide definitions with -f")
+		}
+		want, err := loadDefinitions(*path, *app)
+		if err != nil {
+			return usageErrorf("%s", err.Error())
+		}
+		if len(want) == 0 {
+			return usageErrorf("no saved search definitions in %s", *path)
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		var have []splunk.SavedSearch
+		apps := map[string]bool{}
+		for _, w := range want {
+			if apps[w.App] {
+				continue
+			}
+			apps[w.App] = true
+			found, err := cli.ListSavedSearchesContext(ctx, splunk.Namespace{App: w.App})
+			if err != nil {
+				return err
+			}
+			for _, s := range found {
+				if s.App == w.App { // globally shared searches of other apps are listed too
+					have = append(have, s)
+				}
+			}
+		}
+		steps, err := splunk.PlanSavedSearches(want, have, *prune)
+		if err != nil {
+			return usageErrorf("%s", err.Error())
+		}
+
+		p := palette{on: !*noColor && os.Getenv("NO_COLOR") == "" && terminal.IsTerminal(int(os.Stdout.Fd()))}
+		if printPlan(os.Stdout, steps, p) == 0 || *dryRun {
+			return nil
+		}
+		if !*yes {
+			ok, err := confirm("Apply these changes?")
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errors.New("apply cancelled")
+			}
+		}
+		for _, s := range steps {
+			switch s.Action {
+			case splunk.PlanCreate:
+				err = cli.CreateSavedSearchContext(ctx, s.Want)
+			case splunk.PlanUpdate:
+				w := s.Want
+				w.Owner, w.App = s.Have.Owner, s.Have.App
+				err = cli.UpdateSavedSearchContext(ctx, w)
+			case splunk.PlanDelete:
+				err = cli.DeleteSavedSearchContext(ctx, s.Have.Namespace(), s.Have.Name)
+			default:
+				continue
+			}
+			name := s.Want.App + "/" + s.Want.Name
+			if s.Action == splunk.PlanDelete {
+				name = s.Have.App + "/" + s.Have.Name
+			}
+			if err != nil {
+				return errors.Wrapf(err, "%s %s", s.Action, name)
+			}
+			fmt.Printf("%s: %s\n", name, applied[s.Action])
+		}
+		return nil
+	}
+	return c
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// exportPaths returns a distinct file under `dir` for each of `saved`:
+// <app>/<name>.<format>, under an <owner> directory for searches not
+// shared with nobody. Names that only differ in the characters unsafe in
+// file names, or in case, get a -2, -3... suffix
+func exportPaths(dir, format string, saved []splunk.SavedSearch) []string {
+	var ret []string
+	used := map[string]bool{}
+	for _, s := range saved {
+		parts := []string{dir, unsafeFileChars.ReplaceAllString(s.App, "_")}
+		if s.Owner != "" {
+			parts = append(parts, unsafeFileChars.ReplaceAllString(s.Owner, "_"))
+		}
+		base := filepath.Join(append(parts, unsafeFileChars.ReplaceAllString(s.Name, "_"))...)
+		path := base + "." + format
+		for n := 2; used[strings.ToLower(path)]; n++ {
+			path = fmt.Sprintf("%s-%d.%s", base, n, format)
+		}
+		used[strings.ToLower(path)] = true
+		ret = append(ret, path)
+	}
+	return ret
+}
+
+func savedExportCmd(a *app) *command {
+	c := newCommand("export", "", "Print saved searches as definitions for splunk saved apply")
+	ns := namespaceFlags(c)
+	dir := c.flags.String("o", "", "write one file per saved search under this directory, in a directory per app and one per owner other than nobody")
+	format := c.flags.String("format", "yaml", "yaml or json")
+	c.run = func(args []string) error {
+		if *format != "yaml" && *format != "json" {
+			return usageErrorf("unknown format %q: must be yaml or json", *format)
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := interruptContext()
+		defer cancel()
+		saved, err := cli.ListSavedSearchesContext(ctx, *ns)
+		if err != nil {
+			return err
+		}
+		for i := range saved {
+			if saved[i].Owner == "nobody" {
+				saved[i].Owner = "" // the default of definitions
+			}
+		}
+		encode := func(v interface{}) ([]byte, error) {
+			if *format == "json" {
+				byts, err := json.MarshalIndent(v, "", "    ")
+				return append(byts, '\n'), err
+			}
+			return yaml.Marshal(v)
+		}
+		if *dir == "" {
+			byts, err := encode(saved)
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(byts)
+			return err
+		}
+		paths := exportPaths(*dir, *format, saved)
+		for i, s := range saved {
+			byts, err := encode(s)
+			if err != nil {
+				return err
+			}
+			path := paths[i]
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(path, byts, 0644); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "%s\n", path)
+		}
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jimmyjames85/splunkcli/pkg/splunk"
+)
+
+func TestExportPaths(t *testing.T) {
+	tests := []struct {
+		name  string
+		saved []splunk.SavedSearch
+		want  []string
+	}{
+		{"distinct", []splunk.SavedSearch{{App: "search", Name: "errors"}, {App: "ops", Name: "errors"}},
+			[]string{"out/search/errors.yaml", "out/ops/errors.yaml"}},
+		{"unsafe characters", []splunk.SavedSearch{{App: "search", Name: "a b"}, {App: "search", Name: "a_b"}, {App: "search", Name: "a/b"}},
+			[]string{"out/search/a_b.yaml", "out/search/a_b-2.yaml", "out/search/a_b-3.yaml"}},
+		{"case", []splunk.SavedSearch{{App: "search", Name: "Errors"}, {App: "search", Name: "errors"}},
+			[]string{"out/search/Errors.yaml", "out/search/errors-2.yaml"}},
+		{"owners", []splunk.SavedSearch{{App: "search", Name: "errors"}, {App: "search", Owner: "alice", Name: "errors"}, {App: "search", Owner: "bob", Name: "errors"}},
+			[]string{"out/search/errors.yaml", "out/search/alice/errors.yaml", "out/search/bob/errors.yaml"}},
+		{"suffix taken", []splunk.SavedSearch{{App: "search", Name: "a-2"}, {App: "search", Name: "a"}, {App: "search", Name: "a?"}},
+			[]string{"out/search/a-2.yaml", "out/search/a.yaml", "out/search/a_.yaml"}},
+		{"suffix of suffix", []splunk.SavedSearch{{App: "search", Name: "a"}, {App: "search", Name: "a-2"}, {App: "search", Name: "a?"}, {App: "search", Name: "a!"}},
+			[]string{"out/search/a.yaml", "out/search/a-2.yaml", "out/search/a_.yaml", "out/search/a_-2.yaml"}},
+	}
+	for _, tt := range tests {
+		if got := exportPaths("out", "yaml", tt.saved); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jimmyjames85/splunkcli/pkg/output"
+	"github.com/jimmyjames85/splunkcli/pkg/spl"
+	"github.com/jimmyjames85/splunkcli/pkg/splunk"
+)
+
+// timeRangeFlags are the flags that set the time range of a search
+type timeRangeFlags struct {
+	earliest, latest, last *string
+	between                *pairValue
+}
+
+func timeFlags(c *command) *timeRangeFlags {
+	return &timeRangeFlags{
+		earliest: c.flags.String("earliest", "", "start of the time range, e.g. -4h@h, @d or 2026-10-01T00:00"),
+		latest:   c.flags.String("latest", "", "end of the time range, e.g. now or -1h@h"),
+		last:     c.flags.String("last", "", "search the last `period`, e.g. 15m, 4h or 7d"),
+		between:  c.pairFlag("between", "the `start end` of the time range, e.g. 2026-10-01T00:00 2026-10-02T00:00"),
+	}
+}
+
+// option validates the time range flags and returns the job parameters
+// they set. Without any, `search` must set earliest= itself so that it
+// does not run over all time, and --latest needs --earliest for the same
+// reason
+func (f *timeRangeFlags) option(search string) (splunk.Option, error) {
+	earliest, latest := *f.earliest, *f.latest
+	switch {
+	case *f.last != "" && (earliest != "" || latest != "" || len(*f.between) > 0):
+		return nil, usageErrorf("--last cannot be combined with --earliest, --latest or --between")
+	case len(*f.between) > 0 && (earliest != "" || latest != ""):
+		return nil, usageErrorf("--between cannot be combined with --earliest or --latest")
+	case len(*f.between) == 1:
+		return nil, usageErrorf("--between takes two times, e.g. --between 2026-10-01T00:00 2026-10-02T00:00")
+	case latest != "" && earliest == "":
+		return nil, usageErrorf("--latest needs --earliest, the search would run over all time otherwise")
+	case *f.last != "":
+		earliest, latest = "-"+strings.TrimPrefix(*f.last, "-"), "now"
+	case len(*f.between) == 2:
+		earliest, latest = (*f.between)[0], (*f.between)[1]
+	}
+
+	inlineEarliest, inlineLatest := splunk.InlineTimeRange(search)
+	if earliest == "" && latest == "" {
+		if inlineEarliest == "" {
+			return nil, usageErrorf("please specify a time range with --earliest, --last or --between, or earliest= in the search: %s", search)
+		}
+		for _, t := range []string{inlineEarliest, inlineLatest} {
+			if err := splunk.ValidateTime(t); t != "" && err != nil {
+				return nil, usageErrorf("%s", err.Error())
+			}
+		}
+		return splunk.WithTimeRange("", ""), nil
+	}
+	if inlineEarliest != "" || inlineLatest != "" {
+		return nil, usageErrorf("the search sets its own time range with earliest= or latest=, remove it to use the time range flags")
+	}
+
+	var err error
+	if earliest != "" {
+		if earliest, err = splunk.NormalizeTime(earliest); err != nil {
+			return nil, usageErrorf("%s", err.Error())
+		}
+	}
+	if latest != "" {
+		if latest, err = splunk.NormalizeTime(latest); err != nil {
+			return nil, usageErrorf("%s", err.Error())
+		}
+	}
+	return splunk.WithTimeRange(earliest, latest), nil
+}
+
+func lintFlag(c *command) *string {
+	return c.flags.String("lint", "warn", "check the SPL before submitting it and report errors and warnings (warn), only errors (error), or nothing (off). Searches with errors are not submitted")
+}
+
+// lint checks `search` locally and prints the findings `mode` reports.
+// Errors, which splunk would reject anyway, fail unless mode is off;
+// warnings are only printed
+func lint(mode, search string) error {
+	switch mode {
+	case "off":
+		return nil
+	case "warn", "error":
+	default:
+		return usageErrorf("unknown --lint %q: must be warn, error or off", mode)
+	}
+	findings := spl.Lint(search)
+	for _, f := range findings {
+		if f.Severity == spl.Error || mode == "warn" {
+			fmt.Fprintf(os.Stderr, "lint: %s\n", f)
+		}
+	}
+	if spl.HasErrors(findings) {
+		return usageErrorf("search not submitted: fix the lint errors above or use --lint=off to submit it anyway")
+	}
+	return nil
+}
+
+func outputFlag(c *command, def string) *string {
+	return c.flags.String("output", def, "output format: "+strings.Join(output.Formats, ", "))
+}
+
+// checkOutput rejects unknown output formats before a job is submitted
+// for nothing
+func checkOutput(format string) error {
+	for _, f := range output.Formats {
+		if f == format {
+			return nil
+		}
+	}
+	return usageErrorf("unknown output format %q: must be one of %s", format, strings.Join(output.Formats, ", "))
+}
+
+// tagsFlag adds --tag, comma separated tags recorded in the history
+func tagsFlag(c *command) *string {
+	return c.flags.String("tag", "", "comma separated tags to record with the search in the history")
+}
+
+// splitTags splits the value of --tag
+func splitTags(s string) []string {
+	var ret []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+// submit starts `search` and records `tags` with it in the history
+func submit(cli *splunk.Client, search, tags string, opts ...splunk.Option) (string, error) {
+	r, err := cli.Search(search, opts...)
+	if err != nil {
+		return "", err
+	}
+	if tags := splitTags(tags); len(tags) > 0 {
+		if err := cli.History.Tag(r.SearchID, tags...); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: unable to tag search %s: %s\n", r.SearchID, err.Error())
+		}
+	}
+	return r.SearchID, nil
+}
+
+func searchCmd(a *app) *command {
+	c := newCommand("search", "<spl>", "Submit a search job and print its search ID")
+	tags := tagsFlag(c)
+	timeRange := timeFlags(c)
+	lintMode := lintFlag(c)
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "search"); err != nil {
+			return err
+		}
+		search := args[0] // fmt.Sprintf("search earliest=-1h host=*filter* event=FilterReceived OR event=processed OR event=drop")
+		opt, err := timeRange.option(search)
+		if err != nil {
+			return err
+		}
+		if err := lint(*lintMode, search); err != nil {
+			return err
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		sid, err := submit(cli, search, *tags, opt)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("{\"searchID\": %q}\n", sid)
+		return nil
+	}
+	return c
+}
+
+func runCmd(a *app) *command {
+	c := newCommand("run", "<spl>", "Submit a search, wait for it to finish and print its results")
+	pageSize := c.flags.Int("page-size", 0, "number of results to fetch per request, 0 for the server's maximum")
+	format := outputFlag(c, output.JSON)
+	tags := tagsFlag(c)
+	timeRange := timeFlags(c)
+	lintMode := lintFlag(c)
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "search"); err != nil {
+			return err
+		}
+		search := args[0]
+		opt, err := timeRange.option(search)
+		if err != nil {
+			return err
+		}
+		if err := lint(*lintMode, search); err != nil {
+			return err
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		return runSearch(cli, search, *tags, *pageSize, *format, opt)
+	}
+	return c
+}
+
+// runSearch submits `search`, waits for it to finish and prints its
+// results, cancelling the job if interrupted
+func runSearch(cli *splunk.Client, search, tags string, pageSize int, format string, opts ...splunk.Option) error {
+	if err := checkOutput(format); err != nil {
+		return err
+	}
+	sid, err := submit(cli, search, tags, opts...)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	bar := newProgressBar(os.Stderr)
+	_, err = cli.WaitForJob(ctx, sid, splunk.WithProgress(bar.Update))
+	bar.Done()
+	if ctx.Err() != nil {
+		if err := cli.CancelJob(sid); err != nil {
+			return interruptedf("interrupted: unable to cancel search %s: %s", sid, err.Error())
+		}
+		return interruptedf("interrupted: cancelled search %s", sid)
+	}
+	if err != nil {
+		return err
+	}
+	return writeResults(ctx, cli.ResultsContext(ctx, sid, pageSize), format)
+}
+
+func exportCmd(a *app) *command {
+	c := newCommand("export", "<spl>", "Stream the results of a search as they are produced")
+	preview := c.flags.Bool("preview", false, "also print preview rows as they are produced")
+	format := outputFlag(c, output.NDJSON)
+	timeRange := timeFlags(c)
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "search"); err != nil {
+			return err
+		}
+		search := args[0]
+		opt, err := timeRange.option(search)
+		if err != nil {
+			return err
+		}
+		w, err := output.New(*format, os.Stdout, nil)
+		if err != nil {
+			return usageErrorf("%s", err.Error())
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+		stream, err := cli.Export(ctx, search, opt)
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+
+		for stream.Next() {
+			row := stream.Row()
+			if row.Preview && !*preview {
+				continue
+			}
+			if err := w.Write(row.Result); err != nil {
+				return err
+			}
+			// tables are flushed in blocks, as rows change the alignment
+			if *format != output.Table && !stream.Buffered() {
+				if err := w.Flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return stream.Err()
+	}
+	return c
+}
+
+func statusCmd(a *app) *command {
+	c := newCommand("status", "<sid>", "Print the status of a search job")
+	raw := c.flags.Bool("raw", false, "print the job entry exactly as splunk returns it")
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "search ID"); err != nil {
+			return err
+		}
+		sid := args[0]
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		if *raw {
+			r, err := cli.GetSearchStatus(sid)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s\n", string(r.Body))
+			return nil
+		}
+		status, err := cli.GetJobStatus(sid)
+		if err != nil {
+			return err
+		}
+		return printJSON(status)
+	}
+	return c
+}
+
+func waitCmd(a *app) *command {
+	c := newCommand("wait", "<sid>", "Wait for a search job to finish, showing its progress")
+	interval := c.flags.Duration("interval", 500*time.Millisecond, "initial delay between status polls")
+	maxInterval := c.flags.Duration("max-interval", 5*time.Second, "largest delay between status polls")
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "search ID"); err != nil {
+			return err
+		}
+		sid := args[0]
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+		bar := newProgressBar(os.Stderr)
+		status, err := cli.WaitForJob(ctx, sid, splunk.WithPollInterval(*interval, *maxInterval), splunk.WithProgress(bar.Update))
+		bar.Done()
+		if splunk.IsAuth(err) {
+			return err
+		}
+		if perr := printJSON(status); perr != nil {
+			return perr
+		}
+		return err
+	}
+	return c
+}
+
+func resultsCmd(a *app) *command {
+	c := newCommand("results", "<sid>", "Print the results of a finished search job")
+	pageSize := c.flags.Int("page-size", 0, "number of results to fetch per request, 0 for the server's maximum")
+	format := outputFlag(c, output.JSON)
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "search ID"); err != nil {
+			return err
+		}
+		sid := args[0]
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+		return writeResults(ctx, cli.ResultsContext(ctx, sid, *pageSize), *format)
+	}
+	return c
+}
+
+func clearCmd(a *app) *command {
+	c := newCommand("clear", "", "Mark searches whose jobs no longer exist on the server as expired in the history")
+	c.run = func(args []string) error {
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		return cli.ClearKnownSearches()
+	}
+	return c
+}
+
+func printJSON(v interface{}) error {
+	byts, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\n", string(byts))
+	return nil
+}
+
+// writeResults renders every result of `it` in `format`
+func writeResults(ctx context.Context, it *splunk.ResultsIterator, format string) error {
+	// fields are known once the first page is fetched
+	more := it.Next()
+	if err := it.Err(); err != nil {
+		return err
+	}
+	w, err := output.New(format, os.Stdout, it.Fields())
+	if err != nil {
+		return err
+	}
+	for ; more; more = it.Next() {
+		if ctx.Err() != nil {
+			w.Close()
+			return ctx.Err()
+		}
+		if err := w.Write(it.Result()); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return it.Err()
+}
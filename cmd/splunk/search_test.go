@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestTimeRangeOption(t *testing.T) {
+	tests := []struct {
+		args   []string
+		search string
+		want   string // the encoded job parameters
+		err    string
+	}{
+		{nil, "search earliest=-1h index=main", "", ""},
+		{nil, "search index=main", "", "please specify a time range"},
+		{[]string{"--latest", "-1h"}, "search index=main", "", "--latest needs --earliest"},
+		{[]string{"--latest", "-1h"}, "search earliest=-4h index=main", "", "--latest needs --earliest"},
+		{[]string{"--earliest", "-4h"}, "search index=main", "earliest_time=-4h", ""},
+		{[]string{"--earliest", "-4h", "--latest", "-1h"}, "search index=main", "earliest_time=-4h&latest_time=-1h", ""},
+		{[]string{"--last", "15m"}, "search index=main", "earliest_time=-15m&latest_time=now", ""},
+		{[]string{"--last", "15m", "--latest", "now"}, "search index=main", "", "--last cannot be combined"},
+		{[]string{"--between", "-2d", "-1d"}, "search index=main", "earliest_time=-2d&latest_time=-1d", ""},
+		{[]string{"--earliest", "-4h"}, "search earliest=-1h index=main", "", "sets its own time range"},
+	}
+	for _, tt := range tests {
+		c := newCommand("search", "", "")
+		f := timeFlags(c)
+		if err := c.flags.Parse(c.expandPairs(tt.args)); err != nil {
+			t.Fatal(err)
+		}
+		opt, err := f.option(tt.search)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%v %q: err = %v, want it to contain %q", tt.args, tt.search, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v %q: %v", tt.args, tt.search, err)
+			continue
+		}
+		v := url.Values{}
+		opt(v)
+		if got := v.Encode(); got != tt.want {
+			t.Errorf("%v %q = %s, want %s", tt.args, tt.search, got, tt.want)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		mode, search string
+		refused      bool
+	}{
+		{"warn", "search index=main error | stats count", false},
+		{"warn", "search error | transaction session", false},
+		{"error", "search *error | join host [search index=main]", false},
+		{"warn", `search index=main "error`, true},
+		{"error", "search index=main | stats count by (host", true},
+		{"off", "search index=main | stats count by (host", false},
+	}
+	for _, tt := range tests {
+		if err := lint(tt.mode, tt.search); (err != nil) != tt.refused {
+			t.Errorf("lint(%s, %q) = %v, want refused %v", tt.mode, tt.search, err, tt.refused)
+		}
+	}
+	if err := lint("strict", "search index=main"); err == nil {
+		t.Errorf("lint accepted an unknown mode")
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jimmyjames85/splunkcli/pkg/hec"
+	"github.com/jimmyjames85/splunkcli/pkg/splunk"
+	"github.com/pkg/errors"
+)
+
+func sendCmd(a *app) *command {
+	c := newCommand("send", "", "Send events read from stdin to the HTTP Event Collector")
+	var cfg hec.Config
+	c.flags.StringVar(&cfg.URL, "url", "", "HTTP Event Collector URL, defaults to the profile's host on port 8088")
+	token := c.flags.String("token", "", "HEC token, defaults to SPLUNK_HEC_TOKEN")
+	format := c.flags.String("format", "lines", "lines, json (one JSON value per line) or hec (one event endpoint object per line)")
+	c.flags.BoolVar(&cfg.Raw, "raw", false, "send lines to the raw endpoint, where splunk breaks them into events by sourcetype")
+	c.flags.StringVar(&cfg.Index, "index", "", "index of events that do not name one")
+	c.flags.StringVar(&cfg.Source, "source", "", "source of events that do not name one")
+	c.flags.StringVar(&cfg.SourceType, "sourcetype", "", "sourcetype of events that do not name one")
+	c.flags.StringVar(&cfg.Host, "host", "", "host of events that do not name one")
+	c.flags.IntVar(&cfg.MaxBatchEvents, "batch-events", 100, "send a batch once it holds this many events")
+	c.flags.IntVar(&cfg.MaxBatchBytes, "batch-bytes", 1<<20, "send a batch once it reaches this size before compression")
+	c.flags.DurationVar(&cfg.FlushInterval, "flush-interval", time.Second, "send a batch this long after its first event was read")
+	c.flags.IntVar(&cfg.QueueSize, "queue", 1000, "events read ahead while batches are being sent")
+	c.flags.IntVar(&cfg.Senders, "senders", 4, "batches sent at once, 1 keeps them in order")
+	c.flags.BoolVar(&cfg.Gzip, "gzip", false, "compress the batches")
+	c.flags.BoolVar(&cfg.Ack, "ack", false, "wait for splunk to acknowledge every batch was indexed; needs indexer acknowledgement on the token")
+	c.flags.DurationVar(&cfg.AckTimeout, "ack-timeout", 2*time.Minute, "fail batches not acknowledged within this time")
+	verbose := c.flags.Bool("v", false, "print the outcome of every batch")
+	c.run = func(args []string) error {
+		if *format != "lines" && *format != "json" && *format != "hec" {
+			return usageErrorf("unknown format %q: must be lines, json or hec", *format)
+		}
+		if cfg.Raw && *format == "hec" {
+			return usageErrorf("--format=hec sends metadata the raw endpoint does not accept, use --format=lines or json")
+		}
+		cfg.Token = *token
+		if cfg.Token == "" {
+			cfg.Token = os.Getenv("SPLUNK_HEC_TOKEN")
+		}
+		if cfg.Token == "" {
+			return usageErrorf("please provide a HEC token with --token or SPLUNK_HEC_TOKEN")
+		}
+		if err := a.hecConnection(&cfg); err != nil {
+			return err
+		}
+
+		var mu sync.Mutex
+		var sent, failed, batches int
+		cfg.OnRetry = printRetry
+		cfg.OnBatch = func(r hec.BatchResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			if r.Err != nil {
+				failed += r.Events
+				fmt.Fprintf(os.Stderr, "batch of %d events failed after %d attempts: %s\n", r.Events, r.Attempts, r.Err.Error())
+				return
+			}
+			sent += r.Events
+			batches++
+			if !*verbose {
+				return
+			}
+			ack := ""
+			if r.Acked {
+				ack = fmt.Sprintf(", acknowledged as %d", r.AckID)
+			}
+			fmt.Fprintf(os.Stderr, "sent %d events, %d bytes%s\n", r.Events, r.Bytes, ack)
+		}
+		cli, err := hec.New(cfg)
+		if err != nil {
+			return usageErrorf("%s", err.Error())
+		}
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		skipped, err := readEvents(ctx, os.Stdin, *format, cli.Send)
+		cli.Close(ctx) // failed batches are counted by OnBatch
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(os.Stderr, "%d events sent in %d batches", sent, batches)
+		if cfg.Ack {
+			fmt.Fprintf(os.Stderr, " and acknowledged")
+		}
+		fmt.Fprintln(os.Stderr)
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case failed > 0:
+			return errors.Errorf("%d events were not sent", failed)
+		case skipped > 0:
+			return errors.Errorf("%d lines were skipped", skipped)
+		}
+		return nil
+	}
+	return c
+}
+
+// hecConnection defaults the URL to the profile's host, on the HEC port,
+// and connects with the profile's TLS, connection and retry settings
+func (a *app) hecConnection(cfg *hec.Config) error {
+	var cli *splunk.Client
+	if exists(a.fileloc) {
+		var err error
+		if cli, err = a.load(false); err != nil {
+			return err
+		}
+	}
+	if cfg.URL == "" {
+		if cli == nil {
+			return usageErrorf("please provide the HTTP Event Collector URL with --url")
+		}
+		u, err := url.Parse(cli.Addr)
+		if err != nil {
+			return err
+		}
+		u.Host = net.JoinHostPort(u.Hostname(), "8088")
+		u.Path = ""
+		cfg.URL = u.String()
+	}
+	var o splunk.HTTPOptions
+	var tls *splunk.TLSOptions
+	if cli != nil {
+		tls = cli.TLS
+		if cli.HTTP != nil {
+			o = *cli.HTTP
+		}
+		if cli.Retry != nil {
+			cfg.Retry = *cli.Retry
+		}
+	}
+	if a.http != nil {
+		a.http(&o)
+	}
+	h, err := splunk.NewHTTPClient(tls, &o)
+	if err != nil {
+		return usageErrorf("%s", err.Error())
+	}
+	cfg.HTTPClient = h
+	return nil
+}
+
+// readEvents sends the events of `r` in `format` until EOF, reporting
+// and counting the lines that are not valid events
+func readEvents(ctx context.Context, r io.Reader, format string, send func(context.Context, hec.Event) error) (int, error) {
+	br := bufio.NewReader(r)
+	skipped := 0
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return skipped, err
+		}
+		eof := err == io.EOF
+		line = bytes.TrimRight(line, "\r\n")
+		if len(bytes.TrimSpace(line)) > 0 {
+			var e hec.Event
+			var lerr error
+			switch format {
+			case "lines":
+				e.Event = string(line)
+			case "json":
+				if !json.Valid(line) {
+					lerr = errors.New("invalid JSON")
+				}
+				e.Event = json.RawMessage(line)
+			case "hec":
+				lerr = json.Unmarshal(line, &e)
+			}
+			if lerr != nil {
+				fmt.Fprintf(os.Stderr, "line %d skipped: %s\n", n, lerr.Error())
+				skipped++
+			} else if err := send(ctx, e); err != nil {
+				return skipped, err
+			}
+		}
+		if eof {
+			return skipped, nil
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jimmyjames85/splunkcli/pkg/splunk"
+	"github.com/pkg/errors"
+)
+
+func tokenCmd(a *app) *command {
+	c := newCommand("token", "", "Manage splunk authentication tokens")
+	return c.add(
+		tokenCreateCmd(a),
+		tokenListCmd(a),
+		tokenDeleteCmd(a),
+		tokenRotateCmd(a),
+	)
+}
+
+// tokenUser returns `user` or, if empty, the user the client authenticates as
+func tokenUser(cli *splunk.Client, user string) (string, error) {
+	if user != "" {
+		return user, nil
+	}
+	if cli.Username != "" {
+		return cli.Username, nil
+	}
+	return cli.CurrentUser()
+}
+
+func formatTokenTime(t time.Time, zero string) string {
+	if t.IsZero() {
+		return zero
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+func tokenCreateCmd(a *app) *command {
+	c := newCommand("create", "", "Create a token and print it")
+	audience := c.flags.String("audience", "splunkcli", "what the token is for, e.g. ci")
+	expires := c.flags.String("expires", "+30d", "absolute or relative expiry, e.g. +30d, empty for never")
+	user := c.flags.String("user", "", "user the token is for, defaults to the profile's user")
+	c.run = func(args []string) error {
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		name, err := tokenUser(cli, *user)
+		if err != nil {
+			return err
+		}
+		tok, err := cli.CreateToken(name, *audience, *expires)
+		if err != nil {
+			return err
+		}
+		return printJSON(tok)
+	}
+	return c
+}
+
+func tokenListCmd(a *app) *command {
+	c := newCommand("list", "", "List tokens")
+	c.run = func(args []string) error {
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		tokens, err := cli.ListTokens()
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(tw, "\tID\tUSER\tAUDIENCE\tSTATUS\tEXPIRES\tLAST USED\n")
+		for _, t := range tokens {
+			current := ""
+			if t.ID == cli.TokenID {
+				current = "*"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", current, t.ID, t.User, t.Audience, t.Status,
+				formatTokenTime(t.ExpiresAt, "never"), formatTokenTime(t.LastUsed, "-"))
+		}
+		return tw.Flush()
+	}
+	return c
+}
+
+func tokenDeleteCmd(a *app) *command {
+	c := newCommand("delete", "<id>", "Delete a token")
+	user := c.flags.String("user", "", "owner of the token, looked up if not given")
+	c.run = func(args []string) error {
+		if err := requireArgs(args, 1, "token id"); err != nil {
+			return err
+		}
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		id := args[0]
+		if id == cli.TokenID {
+			return errors.Errorf("token %s is the one profile %s uses, run 'splunk token rotate' instead", id, cli.Profile)
+		}
+		owner := *user
+		if owner == "" {
+			owner, err = tokenOwner(cli, id)
+			if err != nil {
+				return err
+			}
+		}
+		return cli.DeleteToken(owner, id)
+	}
+	return c
+}
+
+// tokenOwner finds the user token `id` belongs to
+func tokenOwner(cli *splunk.Client, id string) (string, error) {
+	tokens, err := cli.ListTokens()
+	if err != nil {
+		return "", err
+	}
+	for _, t := range tokens {
+		if t.ID == id {
+			return t.User, nil
+		}
+	}
+	return "", errors.Errorf("no such token: %s", id)
+}
+
+func tokenRotateCmd(a *app) *command {
+	c := newCommand("rotate", "", "Create a token and make the profile authenticate with it")
+	audience := c.flags.String("audience", "splunkcli", "what the token is for, e.g. ci")
+	expires := c.flags.String("expires", "+30d", "absolute or relative expiry, e.g. +30d, empty for never")
+	keep := c.flags.Bool("keep", false, "keep the token the profile used before instead of deleting it")
+	c.run = func(args []string) error {
+		cli, err := a.client()
+		if err != nil {
+			return err
+		}
+		name, err := tokenUser(cli, "")
+		if err != nil {
+			return err
+		}
+		tok, err := cli.CreateToken(name, *audience, *expires)
+		if err != nil {
+			return err
+		}
+		oldID := cli.TokenID
+		cli.UseToken(tok.Token)
+		cli.TokenID = tok.ID
+		cli.Username = name
+		if err := a.save(); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "profile %s now uses token %s\n", cli.Profile, tok.ID)
+		if oldID == "" || *keep {
+			return nil
+		}
+		if err := cli.DeleteToken(name, oldID); err != nil {
+			return errors.Wrapf(err, "unable to delete previous token %s", oldID)
+		}
+		fmt.Fprintf(os.Stderr, "deleted previous token %s\n", oldID)
+		return nil
+	}
+	return c
+}
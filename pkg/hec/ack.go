@@ -0,0 +1,100 @@
+package hec
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// waiting is a sent batch waiting for its acknowledgement
+type waiting struct {
+	result BatchResult
+	sent   time.Time
+}
+
+// pollAcks asks splunk every Config.AckInterval which of the sent
+// batches were indexed, until the client is closed
+func (c *Client) pollAcks() {
+	defer close(c.ackDone)
+	t := time.NewTicker(c.cfg.AckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			c.checkAcks()
+		case <-c.ctx.Done():
+			c.mu.Lock()
+			var left []BatchResult
+			for id, w := range c.acks {
+				w.result.Err = ErrClosed
+				left = append(left, w.result)
+				delete(c.acks, id)
+			}
+			c.mu.Unlock()
+			for _, r := range left {
+				c.finish(r)
+			}
+			return
+		}
+	}
+}
+
+func (c *Client) checkAcks() {
+	c.mu.Lock()
+	var ids []int64
+	for id := range c.acks {
+		ids = append(ids, id)
+	}
+	c.mu.Unlock()
+	if len(ids) == 0 {
+		return
+	}
+	acked, err := c.queryAcks(ids)
+	now := time.Now()
+	var done []BatchResult
+	c.mu.Lock()
+	for _, id := range ids {
+		w := c.acks[id]
+		switch {
+		case acked[id]:
+			w.result.Acked = true
+		case now.Sub(w.sent) < c.cfg.AckTimeout:
+			continue
+		case err != nil:
+			w.result.Err = fmt.Errorf("%s: %s", ErrAckTimeout.Error(), err.Error())
+		default:
+			w.result.Err = ErrAckTimeout
+		}
+		done = append(done, w.result)
+		delete(c.acks, id)
+	}
+	c.mu.Unlock()
+	for _, r := range done {
+		c.finish(r)
+	}
+}
+
+// queryAcks returns which of the acknowledgement IDs were indexed
+func (c *Client) queryAcks(ids []int64) (map[int64]bool, error) {
+	// curl -k -H 'Authorization: Splunk <token>' -H 'X-Splunk-Request-Channel: <channel>' \
+	//   https://localhost:8088/services/collector/ack -d '{"acks":[0,1]}'
+	body, err := json.Marshal(map[string][]int64{"acks": ids})
+	if err != nil {
+		return nil, err
+	}
+	var r struct {
+		Acks map[string]bool `json:"acks"`
+	}
+	if _, err := c.do(strings.TrimRight(c.cfg.URL, "/")+AckEndpoint, body, nil, &r); err != nil {
+		return nil, err
+	}
+	ret := map[int64]bool{}
+	for k, v := range r.Acks {
+		if id, err := strconv.ParseInt(k, 10, 64); err == nil {
+			ret[id] = v
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,83 @@
+// Package hec sends events to splunk's HTTP Event Collector
+package hec
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Event is an event with the metadata the event endpoint accepts, e.g.
+// {"time":1760700000.123,"sourcetype":"app","event":{"msg":"hi"}}
+type Event struct {
+	Time       time.Time // zero to let splunk pick it, usually the time it arrives
+	Host       string
+	Source     string
+	SourceType string
+	Index      string
+	// Event is a string, a json.RawMessage or anything else that
+	// marshals to JSON
+	Event  interface{}
+	Fields map[string]interface{} // indexed fields
+}
+
+type wireEvent struct {
+	Time       json.Number            `json:"time,omitempty"`
+	Host       string                 `json:"host,omitempty"`
+	Source     string                 `json:"source,omitempty"`
+	SourceType string                 `json:"sourcetype,omitempty"`
+	Index      string                 `json:"index,omitempty"`
+	Event      interface{}            `json:"event"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+}
+
+// MarshalJSON encodes the event as the event endpoint expects, with its
+// time in epoch seconds to the millisecond
+func (e Event) MarshalJSON() ([]byte, error) {
+	w := wireEvent{Host: e.Host, Source: e.Source, SourceType: e.SourceType, Index: e.Index, Event: e.Event, Fields: e.Fields}
+	if !e.Time.IsZero() {
+		w.Time = json.Number(strconv.FormatFloat(float64(e.Time.UnixNano()/int64(time.Millisecond))/1000, 'f', 3, 64))
+	}
+	return json.Marshal(w)
+}
+
+// UnmarshalJSON decodes an event in the format of the event endpoint. The
+// time may be a number or a string of one, Event is a json.RawMessage
+func (e *Event) UnmarshalJSON(byts []byte) error {
+	var w struct {
+		wireEvent
+		Event json.RawMessage `json:"event"`
+	}
+	if err := json.Unmarshal(byts, &w); err != nil {
+		return err
+	}
+	if len(w.Event) == 0 || string(w.Event) == "null" {
+		return fmt.Errorf("event is missing")
+	}
+	*e = Event{Host: w.Host, Source: w.Source, SourceType: w.SourceType, Index: w.Index, Event: w.Event, Fields: w.Fields}
+	if w.Time == "" {
+		return nil
+	}
+	secs, err := w.Time.Float64()
+	if err != nil {
+		return fmt.Errorf("invalid time %q", string(w.Time))
+	}
+	whole, frac := math.Modf(secs)
+	e.Time = time.Unix(int64(whole), int64(math.Round(frac*1000))*int64(time.Millisecond))
+	return nil
+}
+
+// rawText is the text of an event as sent to the raw endpoint
+func rawText(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case json.RawMessage:
+		return v, nil
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,73 @@
+package hec
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEventMarshalJSON(t *testing.T) {
+	tests := []struct {
+		event Event
+		want  string
+	}{
+		{Event{Event: "hello"}, `{"event":"hello"}`},
+		{Event{Time: time.Unix(1760700000, 123456789), Event: "x"}, `{"time":1760700000.123,"event":"x"}`},
+		{Event{Host: "h", Source: "s", SourceType: "st", Index: "main", Event: map[string]int{"n": 1}, Fields: map[string]interface{}{"k": "v"}},
+			`{"host":"h","source":"s","sourcetype":"st","index":"main","event":{"n":1},"fields":{"k":"v"}}`},
+		{Event{Event: json.RawMessage(`{"a":[1,2]}`)}, `{"event":{"a":[1,2]}}`},
+	}
+	for _, tt := range tests {
+		byts, err := json.Marshal(tt.event)
+		if err != nil || string(byts) != tt.want {
+			t.Errorf("Marshal(%+v) = %s, %v, want %s", tt.event, byts, err, tt.want)
+		}
+	}
+}
+
+func TestEventUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Event
+		err  bool
+	}{
+		{in: `{"event":"hi"}`, want: Event{Event: json.RawMessage(`"hi"`)}},
+		{in: `{"event":{"a":1},"time":1760700000.5,"index":"main","fields":{"k":"v"}}`,
+			want: Event{Time: time.Unix(1760700000, 500*int64(time.Millisecond)), Index: "main", Event: json.RawMessage(`{"a":1}`), Fields: map[string]interface{}{"k": "v"}}},
+		{in: `{"event":"x","time":"1760700000"}`, want: Event{Time: time.Unix(1760700000, 0), Event: json.RawMessage(`"x"`)}},
+		{in: `{"time":1}`, err: true},
+		{in: `{"event":null}`, err: true},
+		{in: `{"event":"x","time":"soon"}`, err: true},
+		{in: `[1]`, err: true},
+	}
+	for _, tt := range tests {
+		var e Event
+		err := json.Unmarshal([]byte(tt.in), &e)
+		if (err != nil) != tt.err {
+			t.Errorf("Unmarshal(%s) error = %v, want error %t", tt.in, err, tt.err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(e, tt.want) {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", tt.in, e, tt.want)
+		}
+	}
+}
+
+func TestRawText(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{"a line", "a line"},
+		{[]byte("bytes"), "bytes"},
+		{json.RawMessage(`{"a":1}`), `{"a":1}`},
+		{map[string]int{"n": 2}, `{"n":2}`},
+	}
+	for _, tt := range tests {
+		got, err := rawText(tt.in)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("rawText(%v) = %s, %v, want %s", tt.in, got, err, tt.want)
+		}
+	}
+}
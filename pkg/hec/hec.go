@@ -0,0 +1,530 @@
+package hec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jimmyjames85/splunkcli/pkg/splunk"
+)
+
+// Endpoints of the HTTP Event Collector
+const (
+	EventEndpoint = "/services/collector/event"
+	RawEndpoint   = "/services/collector/raw"
+	AckEndpoint   = "/services/collector/ack"
+)
+
+// ErrClosed is returned by calls after Close, and is the error of
+// batches still waiting for their acknowledgement when Close gives up
+var ErrClosed = errors.New("hec: client closed")
+
+// ErrAckTimeout is the error of batches splunk did not acknowledge
+// within Config.AckTimeout
+var ErrAckTimeout = errors.New("hec: batch not acknowledged in time")
+
+// Error is a non 2xx response of the HTTP Event Collector, e.g.
+// {"text":"Invalid token","code":4}
+type Error struct {
+	StatusCode int
+	Code       int // the HEC status code, see the HEC documentation
+	Text       string
+}
+
+func (e *Error) Error() string {
+	ret := fmt.Sprintf("hec: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Text != "" {
+		ret += fmt.Sprintf(": %s (code %d)", e.Text, e.Code)
+	}
+	return ret
+}
+
+// Config configures a Client. Only URL and Token are required
+type Config struct {
+	// URL is where the collector listens, e.g. https://splunk:8088
+	URL   string
+	Token string
+	// Raw sends events to RawEndpoint, where splunk breaks them into
+	// events with the rules of their sourcetype. Only Event.Event is
+	// sent, followed by a newline
+	Raw bool
+	// Host, Source, SourceType and Index are the defaults of events that
+	// do not set them, and the metadata of all raw events
+	Host       string
+	Source     string
+	SourceType string
+	Index      string
+
+	// A batch is sent when it holds MaxBatchEvents events, when it
+	// reaches MaxBatchBytes before compression, or FlushInterval after
+	// its first event was queued
+	MaxBatchEvents int           // default 100
+	MaxBatchBytes  int           // default 1 MiB
+	FlushInterval  time.Duration // default 1s
+	// QueueSize is how many events Send queues before it blocks,
+	// default 1000
+	QueueSize int
+	Gzip      bool // compress requests
+	// Senders is how many batches are sent at once, default 4. Batching
+	// waits while all of them are busy, e.g. retrying. With more than
+	// one, batches may arrive out of order
+	Senders int
+
+	// Ack waits for splunk to acknowledge that each batch was indexed,
+	// which needs indexer acknowledgement enabled on the token
+	Ack         bool
+	AckInterval time.Duration // how often to ask, default 1s
+	AckTimeout  time.Duration // default 2m
+	// Channel is the X-Splunk-Request-Channel of the requests, a random
+	// one if empty. Acknowledgement IDs are per channel
+	Channel string
+
+	// Retry decides how batches failing with network errors or 429, 500,
+	// 502, 503 and 504 responses are retried, see splunk.RetryPolicy for
+	// the handling of Retry-After. Zero fields default to
+	// splunk.DefaultRetryPolicy. Since a batch that timed out may have
+	// been indexed anyway, retried events can be duplicated
+	Retry   splunk.RetryPolicy
+	OnRetry func(splunk.RetryEvent)
+	// HTTPClient defaults to splunk.NewHTTPClient(nil, nil)
+	HTTPClient *http.Client
+	// OnBatch is called, from another goroutine, with the outcome of
+	// every batch
+	OnBatch func(BatchResult)
+}
+
+// BatchResult is the outcome of sending a batch
+type BatchResult struct {
+	Events   int
+	Bytes    int // before compression
+	Attempts int
+	AckID    int64 // set if Config.Ack and the batch was sent
+	Acked    bool  // splunk acknowledged the batch was indexed
+	Err      error
+}
+
+// Client sends events in batches from background goroutines, up to
+// Config.Senders at once. It is safe for concurrent use
+type Client struct {
+	cfg      Config
+	http     *http.Client
+	endpoint string // with the query of raw events
+	retry    splunk.RetryPolicy
+	queue    chan item
+	senders  chan struct{} // a slot per batch being sent
+	sending  sync.WaitGroup
+	done     chan struct{} // closed when run and every send returned
+	ackDone  chan struct{} // closed when pollAcks returns
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	closeMu sync.RWMutex
+	closed  bool
+
+	mu      sync.Mutex
+	pending int           // batches neither failed nor done
+	idle    chan struct{} // closed when pending drops to 0
+	err     error         // of the first failed batch since the last wait
+	acks    map[int64]*waiting
+}
+
+// item is a queued event, or a flush marker
+type item struct {
+	data    []byte
+	flushed chan struct{}
+}
+
+// New starts a client
+func New(cfg Config) (*Client, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("hec: invalid URL %q, expected e.g. https://splunk:8088", cfg.URL)
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("hec: no token")
+	}
+	if cfg.MaxBatchEvents <= 0 {
+		cfg.MaxBatchEvents = 100
+	}
+	if cfg.MaxBatchBytes <= 0 {
+		cfg.MaxBatchBytes = 1 << 20
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	if cfg.Senders <= 0 {
+		cfg.Senders = 4
+	}
+	if cfg.AckInterval <= 0 {
+		cfg.AckInterval = time.Second
+	}
+	if cfg.AckTimeout <= 0 {
+		cfg.AckTimeout = 2 * time.Minute
+	}
+	if cfg.Channel == "" {
+		if cfg.Channel, err = newChannel(); err != nil {
+			return nil, err
+		}
+	}
+	c := &Client{
+		cfg:      cfg,
+		http:     cfg.HTTPClient,
+		endpoint: strings.TrimRight(cfg.URL, "/") + EventEndpoint,
+		retry:    cfg.Retry.WithDefaults(),
+		queue:    make(chan item, cfg.QueueSize),
+		senders:  make(chan struct{}, cfg.Senders),
+		done:     make(chan struct{}),
+		ackDone:  make(chan struct{}),
+		idle:     make(chan struct{}),
+		acks:     map[int64]*waiting{},
+	}
+	if c.http == nil {
+		if c.http, err = splunk.NewHTTPClient(nil, nil); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Raw {
+		q := url.Values{}
+		for k, v := range map[string]string{"host": cfg.Host, "source": cfg.Source, "sourcetype": cfg.SourceType, "index": cfg.Index} {
+			if v != "" {
+				q.Set(k, v)
+			}
+		}
+		c.endpoint = strings.TrimRight(cfg.URL, "/") + RawEndpoint
+		if len(q) > 0 {
+			c.endpoint += "?" + q.Encode()
+		}
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	go c.run()
+	if cfg.Ack {
+		go c.pollAcks()
+	} else {
+		close(c.ackDone)
+	}
+	return c, nil
+}
+
+// newChannel returns a random UUID, the format channels must have
+func newChannel() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// Channel returns the channel of the requests
+func (c *Client) Channel() string { return c.cfg.Channel }
+
+// Send queues an event, blocking while the queue is full. Errors of the
+// batch it ends up in are reported to Config.OnBatch and by Flush
+func (c *Client) Send(ctx context.Context, e Event) error {
+	var data []byte
+	var err error
+	if c.cfg.Raw {
+		data, err = rawText(e.Event)
+	} else {
+		if e.Host == "" {
+			e.Host = c.cfg.Host
+		}
+		if e.Source == "" {
+			e.Source = c.cfg.Source
+		}
+		if e.SourceType == "" {
+			e.SourceType = c.cfg.SourceType
+		}
+		if e.Index == "" {
+			e.Index = c.cfg.Index
+		}
+		data, err = json.Marshal(e)
+	}
+	if err != nil {
+		return fmt.Errorf("hec: %s", err.Error())
+	}
+	return c.enqueue(ctx, item{data: append(data, '\n')})
+}
+
+func (c *Client) enqueue(ctx context.Context, it item) error {
+	c.closeMu.RLock()
+	defer c.closeMu.RUnlock()
+	if c.closed {
+		return ErrClosed
+	}
+	select {
+	case c.queue <- it:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush sends the events queued so far and waits until every batch is
+// sent, and acknowledged with Config.Ack. It returns the error of the
+// first batch that failed since the previous Flush
+func (c *Client) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	if err := c.enqueue(ctx, item{flushed: flushed}); err != nil {
+		return err
+	}
+	select {
+	case <-flushed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return c.wait(ctx)
+}
+
+// Close sends the queued events and waits for them like Flush. When
+// `ctx` is done first, the batches left fail and Close returns
+func (c *Client) Close(ctx context.Context) error {
+	c.closeMu.Lock()
+	if c.closed {
+		c.closeMu.Unlock()
+		return ErrClosed
+	}
+	c.closed = true
+	close(c.queue)
+	c.closeMu.Unlock()
+
+	defer func() {
+		c.cancel()
+		<-c.ackDone
+	}()
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		c.cancel()
+		<-c.done
+	}
+	return c.wait(ctx)
+}
+
+// wait waits until no batch is pending
+func (c *Client) wait(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		if c.pending == 0 {
+			err := c.err
+			c.err = nil
+			c.mu.Unlock()
+			return err
+		}
+		idle := c.idle
+		c.mu.Unlock()
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// finish reports the outcome of a pending batch
+func (c *Client) finish(r BatchResult) {
+	if c.cfg.OnBatch != nil {
+		c.cfg.OnBatch(r)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r.Err != nil && c.err == nil {
+		c.err = r.Err
+	}
+	c.pending--
+	if c.pending == 0 {
+		close(c.idle)
+		c.idle = make(chan struct{})
+	}
+}
+
+// run batches the queued events until the queue is closed, handing
+// each batch to a sender once one is free
+func (c *Client) run() {
+	defer close(c.done)
+	defer c.sending.Wait()
+	var batch bytes.Buffer
+	var events int
+	var deadline <-chan time.Time
+	flush := func() {
+		if events > 0 {
+			c.mu.Lock()
+			c.pending++
+			c.mu.Unlock()
+			c.senders <- struct{}{}
+			c.sending.Add(1)
+			go func(body []byte, events int) {
+				defer c.sending.Done()
+				c.send(body, events)
+				<-c.senders
+			}(append([]byte(nil), batch.Bytes()...), events)
+		}
+		batch.Reset()
+		events, deadline = 0, nil
+	}
+	for {
+		select {
+		case it, ok := <-c.queue:
+			switch {
+			case !ok:
+				flush()
+				return
+			case it.flushed != nil:
+				flush()
+				close(it.flushed)
+				continue
+			}
+			if events > 0 && batch.Len()+len(it.data) > c.cfg.MaxBatchBytes {
+				flush()
+			}
+			batch.Write(it.data)
+			events++
+			if events == 1 {
+				deadline = time.After(c.cfg.FlushInterval)
+			}
+			if events >= c.cfg.MaxBatchEvents || batch.Len() >= c.cfg.MaxBatchBytes {
+				flush()
+			}
+		case <-deadline:
+			flush()
+		}
+	}
+}
+
+// reply is the body of HEC responses
+type reply struct {
+	Text  string `json:"text"`
+	Code  int    `json:"code"`
+	AckID *int64 `json:"ackId"`
+}
+
+// send posts a pending batch and, with Config.Ack, leaves it to
+// pollAcks
+func (c *Client) send(body []byte, events int) {
+	res := BatchResult{Events: events, Bytes: len(body)}
+	r, err := c.post(body, &res.Attempts)
+	switch {
+	case err != nil:
+		res.Err = err
+	case !c.cfg.Ack:
+	case r.AckID == nil:
+		res.Err = fmt.Errorf("hec: no ackId in the response, is indexer acknowledgement enabled on the token?")
+	default:
+		res.AckID = *r.AckID
+		c.mu.Lock()
+		c.acks[res.AckID] = &waiting{result: res, sent: time.Now()}
+		c.mu.Unlock()
+		return
+	}
+	c.finish(res)
+}
+
+// post sends a batch, retrying transient failures
+func (c *Client) post(body []byte, attempts *int) (reply, error) {
+	header := http.Header{}
+	if c.cfg.Gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return reply{}, err
+		}
+		if err := zw.Close(); err != nil {
+			return reply{}, err
+		}
+		body = buf.Bytes()
+		header.Set("Content-Encoding", "gzip")
+	}
+	for {
+		*attempts++
+		var r reply
+		resp, err := c.do(c.endpoint, body, header, &r)
+		if err == nil {
+			return r, nil
+		}
+		if !c.retryable(resp) || *attempts >= c.retry.MaxAttempts {
+			return r, err
+		}
+		delay, ok := c.retry.Delay(*attempts, resp)
+		if !ok {
+			return r, err
+		}
+		if c.cfg.OnRetry != nil {
+			ev := splunk.RetryEvent{Method: "POST", Path: strings.SplitN(c.endpoint, "?", 2)[0], Attempt: *attempts, Delay: delay}
+			if resp != nil {
+				ev.StatusCode = resp.StatusCode
+			} else {
+				ev.Err = err
+			}
+			c.cfg.OnRetry(ev)
+		}
+		select {
+		case <-time.After(delay):
+		case <-c.ctx.Done():
+			return r, err
+		}
+	}
+}
+
+func (c *Client) retryable(resp *http.Response) bool {
+	if resp == nil {
+		return c.ctx.Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// do posts `body` to `endpoint` and decodes the reply into `v`. The
+// response is returned along with the error of non 2xx responses
+func (c *Client) do(endpoint string, body []byte, header http.Header, v interface{}) (*http.Response, error) {
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(c.ctx)
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Authorization", "Splunk "+c.cfg.Token)
+	req.Header.Set("X-Splunk-Request-Channel", c.cfg.Channel)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	byts, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		e := &Error{StatusCode: resp.StatusCode}
+		var r reply
+		if json.Unmarshal(byts, &r) == nil {
+			e.Code, e.Text = r.Code, r.Text
+		} else {
+			e.Text = strings.TrimSpace(string(byts))
+		}
+		return resp, e
+	}
+	if err := json.Unmarshal(byts, v); err != nil {
+		return resp, fmt.Errorf("hec: unexpected response: %s", strings.TrimSpace(string(byts)))
+	}
+	return resp, nil
+}
@@ -0,0 +1,423 @@
+package hec
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jimmyjames85/splunkcli/pkg/splunk"
+)
+
+// collector is a fake HTTP Event Collector
+type collector struct {
+	*httptest.Server
+	t *testing.T
+
+	mu       sync.Mutex
+	batches  [][]string // lines of every accepted batch
+	queries  []string   // of raw batches
+	gzipped  int
+	channels map[string]bool
+	failures []int  // statuses to answer before accepting batches
+	after    string // Retry-After of the failures
+	acked    func(id int64, polls int) bool
+	polls    map[int64]int
+
+	delay       time.Duration // before answering event requests
+	inflight    int32
+	maxInflight int32
+}
+
+func newCollector(t *testing.T) *collector {
+	c := &collector{t: t, channels: map[string]bool{}, polls: map[int64]int{},
+		acked: func(int64, int) bool { return true }}
+	c.Server = httptest.NewServer(http.HandlerFunc(c.serve))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func (c *collector) reply(w http.ResponseWriter, status int, v interface{}) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (c *collector) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != AckEndpoint {
+		n := atomic.AddInt32(&c.inflight, 1)
+		defer atomic.AddInt32(&c.inflight, -1)
+		for max := atomic.LoadInt32(&c.maxInflight); n > max && !atomic.CompareAndSwapInt32(&c.maxInflight, max, n); {
+			max = atomic.LoadInt32(&c.maxInflight)
+		}
+		time.Sleep(c.delay)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r.Header.Get("Authorization") != "Splunk secret" {
+		c.reply(w, http.StatusForbidden, reply{Text: "Invalid token", Code: 4})
+		return
+	}
+	channel := r.Header.Get("X-Splunk-Request-Channel")
+	c.channels[channel] = true
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			c.t.Errorf("bad gzip body: %v", err)
+			return
+		}
+		body = zr
+		c.gzipped++
+	}
+	byts, _ := ioutil.ReadAll(body)
+
+	if r.URL.Path == AckEndpoint {
+		var req struct{ Acks []int64 }
+		json.Unmarshal(byts, &req)
+		resp := map[string]map[string]bool{"acks": {}}
+		for _, id := range req.Acks {
+			c.polls[id]++
+			resp["acks"][strconv.FormatInt(id, 10)] = c.acked(id, c.polls[id])
+		}
+		c.reply(w, http.StatusOK, resp)
+		return
+	}
+	if len(c.failures) > 0 {
+		status := c.failures[0]
+		c.failures = c.failures[1:]
+		if c.after != "" {
+			w.Header().Set("Retry-After", c.after)
+		}
+		c.reply(w, status, reply{Text: "Server is busy", Code: 9})
+		return
+	}
+	if r.URL.Path == RawEndpoint {
+		c.queries = append(c.queries, r.URL.RawQuery)
+	} else if r.URL.Path != EventEndpoint {
+		c.reply(w, http.StatusNotFound, reply{Text: "not found", Code: 404})
+		return
+	}
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(byts))
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	id := int64(len(c.batches))
+	c.batches = append(c.batches, lines)
+	c.reply(w, http.StatusOK, map[string]interface{}{"text": "Success", "code": 0, "ackId": id})
+}
+
+func (c *collector) sizes() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ret []int
+	for _, b := range c.batches {
+		ret = append(ret, len(b))
+	}
+	return ret
+}
+
+// results collects the outcome of every batch
+type results struct {
+	mu  sync.Mutex
+	all []BatchResult
+}
+
+func (r *results) add(b BatchResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.all = append(r.all, b)
+}
+
+func (r *results) get() []BatchResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]BatchResult(nil), r.all...)
+}
+
+// newClient starts a client of `c` with `cfg` completed for tests
+func newClient(t *testing.T, c *collector, cfg Config) (*Client, *results) {
+	t.Helper()
+	res := &results{}
+	cfg.URL = c.URL
+	if cfg.Token == "" {
+		cfg.Token = "secret"
+	}
+	cfg.Retry = splunk.RetryPolicy{MinBackoff: splunk.Duration(time.Millisecond), MaxBackoff: splunk.Duration(5 * time.Millisecond)}
+	cfg.OnBatch = res.add
+	cli, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cli, res
+}
+
+func sendN(t *testing.T, cli *Client, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := cli.Send(context.Background(), Event{Event: fmt.Sprintf("event %d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	for _, cfg := range []Config{{Token: "t"}, {URL: "splunk:8088", Token: "t"}, {URL: "https://splunk:8088"}} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New(%+v) succeeded, want an error", cfg)
+		}
+	}
+}
+
+func TestBatching(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    Config
+		events int
+		sizes  []int
+	}{
+		{"by count", Config{MaxBatchEvents: 3}, 7, []int{3, 3, 1}},
+		// each event is {"event":"event N"} and a newline, 20 bytes
+		{"by size", Config{MaxBatchBytes: 50}, 5, []int{2, 2, 1}},
+		{"oversized event", Config{MaxBatchBytes: 10}, 2, []int{1, 1}},
+	}
+	for _, tt := range tests {
+		c := newCollector(t)
+		tt.cfg.Senders = 1 // in order
+		cli, res := newClient(t, c, tt.cfg)
+		sendN(t, cli, tt.events)
+		if err := cli.Close(context.Background()); err != nil {
+			t.Fatalf("%s: Close: %v", tt.name, err)
+		}
+		if got := c.sizes(); !reflect.DeepEqual(got, tt.sizes) {
+			t.Errorf("%s: batch sizes = %v, want %v", tt.name, got, tt.sizes)
+		}
+		if n := len(res.get()); n != len(tt.sizes) {
+			t.Errorf("%s: %d batch results, want %d", tt.name, n, len(tt.sizes))
+		}
+	}
+}
+
+func TestSenders(t *testing.T) {
+	tests := []struct {
+		senders int
+		max     int32
+	}{
+		{1, 1},
+		{3, 3},
+	}
+	for _, tt := range tests {
+		c := newCollector(t)
+		c.delay = 20 * time.Millisecond
+		cli, _ := newClient(t, c, Config{MaxBatchEvents: 1, Senders: tt.senders})
+		sendN(t, cli, 6)
+		if err := cli.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got := atomic.LoadInt32(&c.maxInflight); got != tt.max {
+			t.Errorf("%d senders: %d batches sent at once, want %d", tt.senders, got, tt.max)
+		}
+		if len(c.batches) != 6 {
+			t.Errorf("%d senders: %d batches, want 6", tt.senders, len(c.batches))
+		}
+		if tt.senders == 1 {
+			for i, b := range c.batches {
+				if want := fmt.Sprintf(`{"event":"event %d"}`, i); len(b) != 1 || b[0] != want {
+					t.Errorf("batch %d = %v, want %s", i, b, want)
+				}
+			}
+		}
+	}
+}
+
+func TestFlushInterval(t *testing.T) {
+	c := newCollector(t)
+	cli, _ := newClient(t, c, Config{FlushInterval: 10 * time.Millisecond})
+	defer cli.Close(context.Background())
+	sendN(t, cli, 2)
+	deadline := time.Now().Add(2 * time.Second)
+	for len(c.sizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := c.sizes(); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("batch sizes = %v, want [2] without a flush", got)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	c := newCollector(t)
+	cli, _ := newClient(t, c, Config{FlushInterval: time.Hour})
+	defer cli.Close(context.Background())
+	sendN(t, cli, 2)
+	if err := cli.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	sendN(t, cli, 1)
+	if err := cli.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.sizes(); !reflect.DeepEqual(got, []int{2, 1}) {
+		t.Errorf("batch sizes = %v, want [2 1]", got)
+	}
+}
+
+func TestEventDefaults(t *testing.T) {
+	c := newCollector(t)
+	cli, _ := newClient(t, c, Config{Index: "main", SourceType: "app", Gzip: true})
+	cli.Send(context.Background(), Event{Event: "a"})
+	cli.Send(context.Background(), Event{Event: "b", Index: "other"})
+	if err := cli.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{`{"sourcetype":"app","index":"main","event":"a"}`, `{"sourcetype":"app","index":"other","event":"b"}`}}
+	if !reflect.DeepEqual(c.batches, want) {
+		t.Errorf("batches = %v, want %v", c.batches, want)
+	}
+	if c.gzipped != 1 {
+		t.Errorf("%d gzipped requests, want 1", c.gzipped)
+	}
+	if !c.channels[cli.Channel()] || len(cli.Channel()) != 36 {
+		t.Errorf("channel %q not sent or not a UUID", cli.Channel())
+	}
+}
+
+func TestRaw(t *testing.T) {
+	c := newCollector(t)
+	cli, _ := newClient(t, c, Config{Raw: true, Index: "main", Host: "web 1"})
+	cli.Send(context.Background(), Event{Event: "127.0.0.1 GET /"})
+	cli.Send(context.Background(), Event{Event: map[string]int{"n": 1}, Index: "ignored"})
+	if err := cli.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"127.0.0.1 GET /", `{"n":1}`}}; !reflect.DeepEqual(c.batches, want) {
+		t.Errorf("batches = %v, want %v", c.batches, want)
+	}
+	if want := []string{"host=web+1&index=main"}; !reflect.DeepEqual(c.queries, want) {
+		t.Errorf("queries = %v, want %v", c.queries, want)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures []int
+		after    string
+		attempts int
+		status   int // of the error, 0 for success
+	}{
+		{"transient", []int{503, 429, 502}, "", 4, 0},
+		{"exhausted", []int{503, 503, 503, 503}, "", 4, 503},
+		{"not retried", []int{400}, "", 1, 400},
+		{"retry after", []int{429}, "0", 2, 0},
+		{"retry after too long", []int{503}, "86400", 1, 503},
+	}
+	for _, tt := range tests {
+		c := newCollector(t)
+		c.failures, c.after = tt.failures, tt.after
+		var retries int
+		cli, res := newClient(t, c, Config{})
+		cli.cfg.OnRetry = func(splunk.RetryEvent) { retries++ }
+		sendN(t, cli, 1)
+		err := cli.Flush(context.Background())
+		cli.Close(context.Background())
+		r := res.get()
+		if len(r) != 1 || r[0].Attempts != tt.attempts || retries != tt.attempts-1 {
+			t.Errorf("%s: results %+v after %d retries, want 1 result after %d attempts", tt.name, r, retries, tt.attempts)
+			continue
+		}
+		if tt.status == 0 {
+			if err != nil || r[0].Err != nil {
+				t.Errorf("%s: Flush = %v, batch error %v, want success", tt.name, err, r[0].Err)
+			}
+			continue
+		}
+		e, ok := r[0].Err.(*Error)
+		if !ok || e.StatusCode != tt.status || e.Code != 9 || err != r[0].Err {
+			t.Errorf("%s: Flush = %v, batch error %#v, want a %d *Error", tt.name, err, r[0].Err, tt.status)
+		}
+	}
+}
+
+func TestInvalidToken(t *testing.T) {
+	c := newCollector(t)
+	cli, _ := newClient(t, c, Config{Token: "wrong"})
+	sendN(t, cli, 1)
+	err := cli.Close(context.Background())
+	if e, ok := err.(*Error); !ok || e.StatusCode != http.StatusForbidden || e.Text != "Invalid token" {
+		t.Errorf("Close = %v, want the 403 Invalid token", err)
+	}
+}
+
+func TestAck(t *testing.T) {
+	c := newCollector(t)
+	// acknowledged on the third poll
+	c.acked = func(id int64, polls int) bool { return polls >= 3 }
+	cli, res := newClient(t, c, Config{Ack: true, AckInterval: 5 * time.Millisecond, MaxBatchEvents: 2})
+	sendN(t, cli, 3)
+	if err := cli.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	r := res.get()
+	if len(r) != 2 {
+		t.Fatalf("results = %+v, want 2 once flushed", r)
+	}
+	ids := map[int64]bool{}
+	for _, b := range r {
+		if !b.Acked || b.Err != nil {
+			t.Errorf("batch %+v not acknowledged", b)
+		}
+		ids[b.AckID] = true
+	}
+	if !ids[0] || !ids[1] {
+		t.Errorf("ack IDs = %v, want 0 and 1", ids)
+	}
+	if err := cli.Close(context.Background()); err != nil {
+		t.Errorf("Close = %v", err)
+	}
+}
+
+func TestAckTimeout(t *testing.T) {
+	c := newCollector(t)
+	c.acked = func(int64, int) bool { return false }
+	cli, res := newClient(t, c, Config{Ack: true, AckInterval: 5 * time.Millisecond, AckTimeout: 30 * time.Millisecond})
+	sendN(t, cli, 1)
+	if err := cli.Close(context.Background()); err != ErrAckTimeout {
+		t.Errorf("Close = %v, want ErrAckTimeout", err)
+	}
+	if r := res.get(); len(r) != 1 || r[0].Acked || r[0].Err != ErrAckTimeout {
+		t.Errorf("results = %+v, want one batch failing with ErrAckTimeout", r)
+	}
+}
+
+func TestCloseGivesUp(t *testing.T) {
+	c := newCollector(t)
+	c.acked = func(int64, int) bool { return false }
+	cli, res := newClient(t, c, Config{Ack: true, AckInterval: 5 * time.Millisecond})
+	sendN(t, cli, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := cli.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Close = %v, want context.DeadlineExceeded", err)
+	}
+	if r := res.get(); len(r) != 1 || r[0].Err != ErrClosed {
+		t.Errorf("results = %+v, want one batch failing with ErrClosed", r)
+	}
+	if err := cli.Send(context.Background(), Event{Event: "late"}); err != ErrClosed {
+		t.Errorf("Send after Close = %v, want ErrClosed", err)
+	}
+	if err := cli.Close(context.Background()); err != ErrClosed {
+		t.Errorf("second Close = %v, want ErrClosed", err)
+	}
+}
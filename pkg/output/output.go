@@ -0,0 +1,219 @@
+// Package output renders splunk search results as tables, CSV,
+// newline delimited JSON, raw events or a JSON array
+package output
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jimmyjames85/splunkcli/pkg/splunk"
+)
+
+// Supported formats
+const (
+	Table  = "table"
+	CSV    = "csv"
+	NDJSON = "ndjson"
+	Raw    = "raw"
+	JSON   = "json"
+)
+
+// Formats lists every format accepted by New
+var Formats = []string{Table, CSV, NDJSON, Raw, JSON}
+
+// Writer renders results one at a time. Flush writes out what is
+// buffered, e.g. while waiting for more results. Close must be called to
+// flush anything buffered; it does not close the underlying io.Writer
+type Writer interface {
+	Write(r splunk.Result) error
+	Flush() error
+	Close() error
+}
+
+// tableBlock is how many rows of a table are aligned together, which
+// bounds the memory tables use
+const tableBlock = 1000
+
+// New returns a Writer for `format`. `fields` selects and orders the
+// columns of the table and csv formats; when empty the columns are
+// taken from the first result written
+func New(format string, w io.Writer, fields []string) (Writer, error) {
+	switch format {
+	case Table:
+		return &tableWriter{tw: tabwriter.NewWriter(w, 0, 8, 2, ' ', 0), columns: Columns(fields)}, nil
+	case CSV:
+		return &csvWriter{cw: csv.NewWriter(w), columns: Columns(fields)}, nil
+	case NDJSON:
+		bw := bufio.NewWriter(w)
+		return &ndjsonWriter{bw: bw, enc: json.NewEncoder(bw)}, nil
+	case Raw:
+		return &rawWriter{bw: bufio.NewWriter(w)}, nil
+	case JSON:
+		return &jsonWriter{bw: bufio.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q: must be one of %s", format, strings.Join(Formats, ", "))
+}
+
+// Columns picks the fields worth displaying: `_time` first, then the
+// regular fields in the given order, then `_raw`. Splunk's other
+// internal fields (_bkt, _cd, _si, ...) are dropped
+func Columns(fields []string) []string {
+	var ret []string
+	var hasTime, hasRaw bool
+	for _, f := range fields {
+		switch {
+		case f == "_time":
+			hasTime = true
+		case f == "_raw":
+			hasRaw = true
+		case !strings.HasPrefix(f, "_"):
+			ret = append(ret, f)
+		}
+	}
+	if hasTime {
+		ret = append([]string{"_time"}, ret...)
+	}
+	if hasRaw {
+		ret = append(ret, "_raw")
+	}
+	return ret
+}
+
+// columnsOf returns the display columns of a single result, with the
+// regular fields sorted since maps carry no order
+func columnsOf(r splunk.Result) []string {
+	var fields []string
+	for f := range r {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return Columns(fields)
+}
+
+type tableWriter struct {
+	tw      *tabwriter.Writer
+	columns []string
+	started bool
+	rows    int // since the last flush
+}
+
+// tableCell keeps a value on one line so it cannot break the alignment
+var tableCell = strings.NewReplacer("\r\n", " ", "\n", " ", "\t", " ")
+
+func (t *tableWriter) Write(r splunk.Result) error {
+	if !t.started {
+		t.started = true
+		if len(t.columns) == 0 {
+			t.columns = columnsOf(r)
+		}
+		if _, err := fmt.Fprintln(t.tw, strings.Join(t.columns, "\t")); err != nil {
+			return err
+		}
+	}
+	cells := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		cells[i] = tableCell.Replace(strings.Join(r.Values(c), ","))
+	}
+	if _, err := fmt.Fprintln(t.tw, strings.Join(cells, "\t")); err != nil {
+		return err
+	}
+	if t.rows++; t.rows >= tableBlock {
+		return t.Flush()
+	}
+	return nil
+}
+
+// Flush writes the rows so far, aligned among themselves
+func (t *tableWriter) Flush() error {
+	t.rows = 0
+	return t.tw.Flush()
+}
+
+func (t *tableWriter) Close() error { return t.Flush() }
+
+type csvWriter struct {
+	cw      *csv.Writer
+	columns []string
+	started bool
+}
+
+func (c *csvWriter) Write(r splunk.Result) error {
+	if !c.started {
+		c.started = true
+		if len(c.columns) == 0 {
+			c.columns = columnsOf(r)
+		}
+		if err := c.cw.Write(c.columns); err != nil {
+			return err
+		}
+	}
+	record := make([]string, len(c.columns))
+	for i, col := range c.columns {
+		record[i] = r.String(col)
+	}
+	return c.cw.Write(record)
+}
+
+func (c *csvWriter) Flush() error {
+	c.cw.Flush()
+	return c.cw.Error()
+}
+
+func (c *csvWriter) Close() error { return c.Flush() }
+
+type ndjsonWriter struct {
+	bw  *bufio.Writer
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(r splunk.Result) error { return n.enc.Encode(r) }
+func (n *ndjsonWriter) Flush() error                { return n.bw.Flush() }
+func (n *ndjsonWriter) Close() error                { return n.bw.Flush() }
+
+type rawWriter struct {
+	bw *bufio.Writer
+}
+
+func (w *rawWriter) Write(r splunk.Result) error {
+	_, err := fmt.Fprintln(w.bw, r.Raw())
+	return err
+}
+
+func (w *rawWriter) Flush() error { return w.bw.Flush() }
+func (w *rawWriter) Close() error { return w.bw.Flush() }
+
+// jsonWriter streams a single JSON array without holding the results
+type jsonWriter struct {
+	bw      *bufio.Writer
+	started bool
+}
+
+func (j *jsonWriter) Write(r splunk.Result) error {
+	byts, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if !j.started {
+		j.started = true
+		sep = "[\n"
+	}
+	_, err = fmt.Fprintf(j.bw, "%s%s", sep, byts)
+	return err
+}
+
+func (j *jsonWriter) Flush() error { return j.bw.Flush() }
+
+func (j *jsonWriter) Close() error {
+	if !j.started {
+		j.bw.WriteString("[")
+	}
+	j.bw.WriteString("\n]\n")
+	return j.bw.Flush()
+}
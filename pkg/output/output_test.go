@@ -0,0 +1,135 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jimmyjames85/splunkcli/pkg/splunk"
+)
+
+// results are as decoded from splunk, multi-value fields as arrays
+var results = []splunk.Result{
+	{"_time": "2026-10-17T05:00:00.000+00:00", "_raw": "GET /a 200", "_bkt": "main~1", "host": "web1",
+		"status": "200", "msg": `said "hi", left`, "tags": []interface{}{"a", "b"}},
+	{"_time": "2026-10-17T05:00:01.000+00:00", "_raw": "GET /b\t500\nretry", "host": "web2",
+		"status": "500", "msg": "tab\there"},
+}
+
+func render(t *testing.T, format string, fields []string, rs []splunk.Result) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := New(format, &buf, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rs {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestColumns(t *testing.T) {
+	tests := []struct {
+		fields, want []string
+	}{
+		{nil, nil},
+		{[]string{"status", "host"}, []string{"status", "host"}},
+		{[]string{"_raw", "host", "_bkt", "_time", "status", "_cd"}, []string{"_time", "host", "status", "_raw"}},
+		{[]string{"_si", "_serial"}, nil},
+	}
+	for _, tt := range tests {
+		if got := Columns(tt.fields); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Columns(%v) = %v, want %v", tt.fields, got, tt.want)
+		}
+	}
+}
+
+func TestWriters(t *testing.T) {
+	tests := []struct {
+		format string
+		fields []string
+		rs     []splunk.Result
+		want   string
+	}{
+		{Table, []string{"host", "status"}, results, "" +
+			"host  status\n" +
+			"web1  200\n" +
+			"web2  500\n"},
+		// columns from the first result, internal fields dropped, values on one line
+		{Table, nil, results, "" +
+			"_time                          host  msg              status  tags  _raw\n" +
+			"2026-10-17T05:00:00.000+00:00  web1  said \"hi\", left  200     a,b   GET /a 200\n" +
+			"2026-10-17T05:00:01.000+00:00  web2  tab here         500           GET /b 500 retry\n"},
+		{Table, []string{"host"}, nil, ""},
+		{CSV, []string{"status", "msg", "tags"}, results, "" +
+			"status,msg,tags\n" +
+			"200,\"said \"\"hi\"\", left\",\"a\nb\"\n" +
+			"500,tab\there,\n"},
+		{CSV, nil, results[1:], "" +
+			"_time,host,msg,status,_raw\n" +
+			"2026-10-17T05:00:01.000+00:00,web2,tab\there,500,\"GET /b\t500\nretry\"\n"},
+		{Raw, nil, results, "GET /a 200\nGET /b\t500\nretry\n"},
+		{JSON, nil, nil, "[\n]\n"},
+	}
+	for _, tt := range tests {
+		if got := render(t, tt.format, tt.fields, tt.rs); got != tt.want {
+			t.Errorf("%s %v:\ngot:\n%s\nwant:\n%s", tt.format, tt.fields, got, tt.want)
+		}
+	}
+}
+
+func TestJSONFormats(t *testing.T) {
+	for _, format := range []string{NDJSON, JSON} {
+		out := render(t, format, []string{"host"}, results)
+		var got []splunk.Result
+		if format == NDJSON {
+			lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+			for _, l := range lines {
+				var r splunk.Result
+				if err := json.Unmarshal([]byte(l), &r); err != nil {
+					t.Fatalf("%s: line %q: %v", format, l, err)
+				}
+				got = append(got, r)
+			}
+		} else if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("%s: %v in %s", format, err, out)
+		}
+		// every field is kept, fields only selects columns
+		if !reflect.DeepEqual(got, results) {
+			t.Errorf("%s: got %v, want %v", format, got, results)
+		}
+	}
+}
+
+func TestTableBlocks(t *testing.T) {
+	rs := make([]splunk.Result, tableBlock+1)
+	for i := range rs {
+		rs[i] = splunk.Result{"n": fmt.Sprint(i)}
+	}
+	lines := strings.Split(strings.TrimSuffix(render(t, Table, []string{"n", "host"}, rs), "\n"), "\n")
+	if len(lines) != tableBlock+2 {
+		t.Fatalf("%d lines, want a header and %d rows", len(lines), tableBlock+1)
+	}
+	// aligned within blocks: the last row is alone in its block
+	if want := "n    host"; lines[0] != want {
+		t.Errorf("header = %q, want %q", lines[0], want)
+	}
+	if want := "1000  "; lines[len(lines)-1] != want {
+		t.Errorf("last row = %q, want %q", lines[len(lines)-1], want)
+	}
+}
+
+func TestNewUnknownFormat(t *testing.T) {
+	if _, err := New("xml", &bytes.Buffer{}, nil); err == nil {
+		t.Errorf("New accepted an unknown format")
+	}
+}
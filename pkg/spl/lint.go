@@ -0,0 +1,309 @@
+package spl
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Severity of a Finding
+type Severity int
+
+// Severities. Errors are searches splunk is certain to reject, warnings
+// are searches that are likely slow or wrong
+const (
+	Warning Severity = iota
+	Error
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// Rules reported in Finding.Rule
+const (
+	RuleQuotes          = "quotes"
+	RuleParens          = "parens"
+	RuleUnknownCommand  = "unknown-command"
+	RuleMissingIndex    = "missing-index"
+	RuleLeadingWildcard = "leading-wildcard"
+	RuleJoin            = "join-limits"
+	RuleTransaction     = "transaction-unbounded"
+)
+
+// Finding is a problem found in a search
+type Finding struct {
+	Severity Severity
+	Rule     string
+	Pos      int // byte offset in the search
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s at %d: %s", f.Severity, f.Rule, f.Pos, f.Message)
+}
+
+// HasErrors reports whether any of `findings` is an Error
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Lint checks a search as submitted to the search jobs API, which must
+// start with the search command or a pipe, and returns its findings
+// ordered by position
+func Lint(search string) []Finding {
+	var ret []Finding
+	tokens, err := Tokenize(search)
+	if terr, ok := err.(*SyntaxError); ok {
+		ret = append(ret, Finding{Error, RuleQuotes, terr.Pos, terr.Msg})
+	}
+	ret = append(ret, balance(tokens)...)
+	ret = append(ret, lintPipeline(tokens, false)...)
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Pos < ret[j].Pos })
+	return ret
+}
+
+// balance reports unmatched parentheses and brackets
+func balance(tokens []Token) []Finding {
+	var ret []Finding
+	var open []Token
+	for _, t := range tokens {
+		switch t.Kind {
+		case LParen, LBracket:
+			open = append(open, t)
+		case RParen, RBracket:
+			want := LParen
+			if t.Kind == RBracket {
+				want = LBracket
+			}
+			if len(open) == 0 || open[len(open)-1].Kind != want {
+				ret = append(ret, Finding{Error, RuleParens, t.Pos, fmt.Sprintf("unexpected %s", t.Text)})
+				continue
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	for _, t := range open {
+		ret = append(ret, Finding{Error, RuleParens, t.Pos, fmt.Sprintf("unclosed %s", t.Text)})
+	}
+	return ret
+}
+
+// command is one stage of a pipeline
+type command struct {
+	name        Token
+	args        []Token   // subsearches excluded
+	subsearches [][]Token // the tokens between [ and ]
+}
+
+// split breaks a pipeline into its commands. It reports whether the
+// pipeline starts with a pipe, i.e. with a generating command
+func split(tokens []Token) ([]command, bool) {
+	var ret []command
+	var cur *command
+	leadingPipe := len(tokens) > 0 && tokens[0].Kind == Pipe
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.Kind == Pipe {
+			cur = nil
+			continue
+		}
+		if cur == nil {
+			ret = append(ret, command{name: t})
+			cur = &ret[len(ret)-1]
+			if t.Kind != LBracket {
+				continue
+			}
+		}
+		if t.Kind != LBracket {
+			cur.args = append(cur.args, t)
+			continue
+		}
+		depth, start := 1, i+1
+		for i++; i < len(tokens) && depth > 0; i++ {
+			switch tokens[i].Kind {
+			case LBracket:
+				depth++
+			case RBracket:
+				depth--
+			}
+		}
+		end := i
+		if depth == 0 {
+			end-- // leave out the ]
+		}
+		cur.subsearches = append(cur.subsearches, tokens[start:end])
+		i--
+	}
+	return ret, leadingPipe
+}
+
+func lintPipeline(tokens []Token, subsearch bool) []Finding {
+	var ret []Finding
+	cmds, leadingPipe := split(tokens)
+	for i, c := range cmds {
+		name := strings.ToLower(c.name.Text)
+		args := c.args
+		implicit := false
+		switch {
+		case c.name.Kind == Macro:
+			continue
+		case c.name.Kind != Word:
+			ret = append(ret, Finding{Error, RuleUnknownCommand, c.name.Pos, fmt.Sprintf("expected a command, found %s", c.name.Text)})
+			continue
+		case i == 0 && !leadingPipe && !commands[name]:
+			if !subsearch {
+				ret = append(ret, Finding{Error, RuleUnknownCommand, c.name.Pos,
+					fmt.Sprintf("%q is not a command: searches sent to the API must start with search or |, e.g. search %s", c.name.Text, c.name.Text)})
+			}
+			// subsearches are searches by default
+			name, args, implicit = "search", append([]Token{c.name}, c.args...), true
+		case !commands[name]:
+			if isIdentifier(name) {
+				ret = append(ret, Finding{Warning, RuleUnknownCommand, c.name.Pos, fmt.Sprintf("unknown command %q", c.name.Text)})
+			} else {
+				ret = append(ret, Finding{Error, RuleUnknownCommand, c.name.Pos, fmt.Sprintf("%q is not a command", c.name.Text)})
+			}
+		}
+		if name == "search" {
+			ret = append(ret, leadingWildcards(args)...)
+			if i == 0 && !subsearch && !leadingPipe && !implicit && !hasIndex(args) {
+				ret = append(ret, Finding{Warning, RuleMissingIndex, c.name.Pos, "no index= in the base search, every default index will be scanned"})
+			}
+		}
+		if name == "join" && !hasOption(args, "max", true) && !hasHead(c.subsearches) {
+			ret = append(ret, Finding{Warning, RuleJoin, c.name.Pos,
+				"join without max= or a head in its subsearch is silently truncated at the subsearch limits, consider stats instead"})
+		}
+		if name == "transaction" && !hasOption(args, "maxspan", false) && !hasOption(args, "maxpause", false) && !hasOption(args, "maxevents", true) {
+			ret = append(ret, Finding{Warning, RuleTransaction, c.name.Pos,
+				"transaction without maxspan=, maxpause= or maxevents= keeps every open transaction in memory"})
+		}
+		for _, sub := range c.subsearches {
+			ret = append(ret, lintPipeline(sub, true)...)
+		}
+	}
+	return ret
+}
+
+func isIdentifier(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isLetter(s[i]) && !isDigit(s[i]) && s[i] != '_' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isLetter(b byte) bool { return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' }
+func isDigit(b byte) bool  { return b >= '0' && b <= '9' }
+
+// hasIndex reports whether the arguments of a search command restrict
+// it to an index, or use a macro that may
+func hasIndex(args []Token) bool {
+	for i, t := range args {
+		if t.Kind == Macro {
+			return true
+		}
+		if t.Kind != Word {
+			continue
+		}
+		lower := strings.ToLower(t.Text)
+		if strings.HasPrefix(lower, "index=") || strings.HasPrefix(lower, "index::") {
+			return true
+		}
+		if lower == "index" && i+1 < len(args) {
+			next := strings.ToLower(args[i+1].Text)
+			if strings.HasPrefix(next, "=") || next == "in" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// leadingWildcards reports search terms and field values starting with
+// a wildcard, which cannot use the index
+func leadingWildcards(args []Token) []Finding {
+	var ret []Finding
+	for i, t := range args {
+		var value string
+		switch {
+		case t.Kind == Word:
+			value = t.Text
+			if eq := strings.IndexAny(value, "=<>"); eq >= 0 {
+				value = strings.TrimLeft(value[eq:], "=<>!")
+			}
+		case t.Kind == String && i > 0 && args[i-1].Kind == Word && strings.HasSuffix(args[i-1].Text, "="):
+			value = strings.Trim(t.Text, `"`)
+		default:
+			continue
+		}
+		if len(value) > 1 && value[0] == '*' && value != strings.Repeat("*", len(value)) {
+			ret = append(ret, Finding{Warning, RuleLeadingWildcard, t.Pos,
+				fmt.Sprintf("%s starts with a wildcard, which scans every event instead of using the index", t.Text)})
+		}
+	}
+	return ret
+}
+
+// hasOption reports whether `name`=value is among the arguments, with a
+// positive number if `positive`
+func hasOption(args []Token, name string, positive bool) bool {
+	for _, t := range args {
+		lower := strings.ToLower(t.Text)
+		if t.Kind != Word || !strings.HasPrefix(lower, name+"=") {
+			continue
+		}
+		if !positive {
+			return true
+		}
+		n, err := strconv.Atoi(lower[len(name)+1:])
+		return err == nil && n > 0
+	}
+	return false
+}
+
+func hasHead(subsearches [][]Token) bool {
+	for _, sub := range subsearches {
+		cmds, _ := split(sub)
+		for _, c := range cmds {
+			if strings.EqualFold(c.name.Text, "head") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// commands are splunk's built in search commands
+var commands = map[string]bool{}
+
+func init() {
+	for _, c := range strings.Fields(`abstract accum addcoltotals addinfo addtotals analyzefields
+		anomalies anomalousvalue anomalydetection append appendcols appendpipe arules associate
+		audit autoregress bin bucket bucketdir chart cluster cofilter collect concurrency
+		contingency convert correlate datamodel dbinspect dedup delete delta diff erex eval
+		eventcount eventstats extract fieldformat fields fieldsummary filldown fillnull findtypes
+		folderize foreach format from gauge gentimes geom geomfilter geostats head highlight
+		history iconify inputcsv inputlookup iplocation join kmeans kv kvform loadjob localize
+		localop lookup makecontinuous makemv makeresults map mcollect metadata metasearch
+		meventcollect mpreview msearch mstats multikv multisearch mvcombine mvexpand nomv outlier
+		outputcsv outputlookup outputtext overlap pivot predict rangemap rare redistribute regex
+		reltime rename replace require rest return reverse rex rtorder run savedsearch script
+		scrub search searchtxn selfjoin sendemail set setfields sichart sirare sistats sitimechart
+		sitop sort spath stats strcat streamstats table tags tail timechart timewrap tojson top
+		transaction transpose trendline tscollect tstats typeahead typelearner typer union uniq
+		untable walklex where x11 xmlkv xmlunescape xpath xyseries`) {
+		commands[c] = true
+	}
+}
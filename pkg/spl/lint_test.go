@@ -0,0 +1,54 @@
+package spl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		search string
+		rules  []string // of the findings, in order
+	}{
+		{`search index=main error | stats count by host`, nil},
+		{`| tstats count where index=main by host`, nil},
+		{"search `my_macro` | head 10", nil},
+		{`search index=main | where x='field(name'`, nil},
+		{`search index=main | eval y='a b' . "c"`, nil},
+		{`search user=o'brien index=main`, nil},
+		{`search index=main msg="unclosed`, []string{RuleQuotes}},
+		{`search index=main | where x='unclosed`, []string{RuleQuotes}},
+		{`search index=main | eval x=if((a>1, 1, 0)`, []string{RuleParens}},
+		{`search index=main | stats count)`, []string{RuleParens}},
+		{`index=main error`, []string{RuleUnknownCommand}},
+		{`search index=main | stast count`, []string{RuleUnknownCommand}},
+		{`search error`, []string{RuleMissingIndex}},
+		{`search index=main *error`, []string{RuleLeadingWildcard}},
+		{`search index=main host=*web | stats count`, []string{RuleLeadingWildcard}},
+		{`search index=main host=* | stats count`, nil},
+		{`search index=main | join host [search index=other]`, []string{RuleJoin}},
+		{`search index=main | join max=0 host [search index=other]`, []string{RuleJoin}},
+		{`search index=main | join host [search index=other | head 100]`, nil},
+		{`search index=main | transaction host`, []string{RuleTransaction}},
+		{`search index=main | transaction host maxspan=5m`, nil},
+		{`search index=main [search *foo | fields host]`, []string{RuleLeadingWildcard}},
+	}
+	for _, tt := range tests {
+		var rules []string
+		for _, f := range Lint(tt.search) {
+			rules = append(rules, f.Rule)
+		}
+		if !reflect.DeepEqual(rules, tt.rules) {
+			t.Errorf("Lint(%q) = %v, want rules %v", tt.search, Lint(tt.search), tt.rules)
+		}
+	}
+}
+
+func TestHasErrors(t *testing.T) {
+	if HasErrors(Lint(`search error`)) {
+		t.Errorf("a missing index is only a warning")
+	}
+	if !HasErrors(Lint(`index=main error`)) {
+		t.Errorf("a search not starting with a command is an error")
+	}
+}
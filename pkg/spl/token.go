@@ -0,0 +1,120 @@
+// Package spl tokenizes splunk's search processing language and lints
+// searches for mistakes that would otherwise only surface after a job
+// has waited in the search queue
+package spl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind is the kind of a Token
+type Kind int
+
+// Token kinds
+const (
+	Word   Kind = iota // anything else, e.g. index=main, stats or count(x)
+	String             // a double quoted string, quotes included
+	Field              // a single quoted field name, e.g. 'user id' in eval
+	Macro              // a `macro(args)`, backticks included
+	Pipe
+	LParen
+	RParen
+	LBracket // starts a subsearch
+	RBracket
+)
+
+// Token is a piece of a search and its byte offset in the search
+type Token struct {
+	Kind Kind
+	Text string
+	Pos  int
+}
+
+// SyntaxError is a tokenizing error at a byte offset of the search
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string { return fmt.Sprintf("at %d: %s", e.Pos, e.Msg) }
+
+var punctuation = map[byte]Kind{'|': Pipe, '(': LParen, ')': RParen, '[': LBracket, ']': RBracket}
+
+// Tokenize splits a search into tokens. On an unterminated string or
+// macro it returns the tokens before it along with a *SyntaxError
+func Tokenize(search string) ([]Token, error) {
+	var ret []Token
+	for i := 0; i < len(search); {
+		c := search[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isPunctuation(c):
+			ret = append(ret, Token{Kind: punctuation[c], Text: search[i : i+1], Pos: i})
+			i++
+		case c == '"':
+			end := closing(search, i, '"')
+			if end < 0 {
+				return ret, &SyntaxError{Pos: i, Msg: "unterminated quote"}
+			}
+			ret = append(ret, Token{Kind: String, Text: search[i : end+1], Pos: i})
+			i = end + 1
+		case c == '\'':
+			end := closing(search, i, '\'')
+			if end < 0 {
+				return ret, &SyntaxError{Pos: i, Msg: "unterminated quote"}
+			}
+			ret = append(ret, Token{Kind: Field, Text: search[i : end+1], Pos: i})
+			i = end + 1
+		case c == '`':
+			end := closing(search, i, '`')
+			if end < 0 {
+				return ret, &SyntaxError{Pos: i, Msg: "unterminated macro, missing `"}
+			}
+			ret = append(ret, Token{Kind: Macro, Text: search[i : end+1], Pos: i})
+			i = end + 1
+		default:
+			start := i
+			for i < len(search) && !wordEnd(search[i]) && !fieldStart(search, i) {
+				i++
+			}
+			ret = append(ret, Token{Kind: Word, Text: search[start:i], Pos: start})
+		}
+	}
+	return ret, nil
+}
+
+// closing returns the offset of the quote closing the one at `start`,
+// skipping backslash escapes, or -1
+func closing(s string, start int, quote byte) int {
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+func isPunctuation(c byte) bool {
+	_, ok := punctuation[c]
+	return ok
+}
+
+// fieldStart reports whether a single quote at `i` starts a field name
+// within a word, i.e. follows an operator as in x='a b'. Apostrophes in
+// search terms such as o'brien are left alone
+func fieldStart(s string, i int) bool {
+	return s[i] == '\'' && i > 0 && strings.IndexByte("=<>!,+-*/%.", s[i-1]) >= 0
+}
+
+func wordEnd(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '"', '`', '|', '(', ')', '[', ']':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package spl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		search string
+		want   []Token
+	}{
+		{`search index=main | stats count by host`, []Token{
+			{Word, "search", 0}, {Word, "index=main", 7}, {Pipe, "|", 18}, {Word, "stats", 20},
+			{Word, "count", 26}, {Word, "by", 32}, {Word, "host", 35}}},
+		{`search msg="a | (b" [search x]`, []Token{
+			{Word, "search", 0}, {Word, "msg=", 7}, {String, `"a | (b"`, 11}, {LBracket, "[", 20},
+			{Word, "search", 21}, {Word, "x", 28}, {RBracket, "]", 29}}},
+		{`where x='field(name'`, []Token{{Word, "where", 0}, {Word, "x=", 6}, {Field, "'field(name'", 8}}},
+		{`eval y='a b'.'c'`, []Token{{Word, "eval", 0}, {Word, "y=", 5}, {Field, "'a b'", 7}, {Word, ".", 12}, {Field, "'c'", 13}}},
+		{`search user=o'brien`, []Token{{Word, "search", 0}, {Word, "user=o'brien", 7}}},
+		{"search `macro(1)` x", []Token{{Word, "search", 0}, {Macro, "`macro(1)`", 7}, {Word, "x", 18}}},
+	}
+	for _, tt := range tests {
+		got, err := Tokenize(tt.search)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %v, %v, want %v", tt.search, got, err, tt.want)
+		}
+	}
+}
+
+func TestTokenizeUnterminated(t *testing.T) {
+	for _, search := range []string{`search "abc`, `where x='abc`, "search `m"} {
+		if _, err := Tokenize(search); err == nil {
+			t.Errorf("Tokenize(%q) succeeded, want a SyntaxError", search)
+		}
+	}
+}
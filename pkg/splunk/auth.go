@@ -0,0 +1,106 @@
+package splunk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Ways a profile can authenticate, see Client.AuthType
+const (
+	AuthSession = "session"
+	AuthToken   = "token"
+	AuthBasic   = "basic"
+)
+
+// Authenticator adds credentials to every request a Client sends
+type Authenticator interface {
+	Authenticate(req *http.Request)
+}
+
+// SessionKeyAuth uses a session key from /services/auth/login
+type SessionKeyAuth struct {
+	SessionKey string
+}
+
+func (a SessionKeyAuth) Authenticate(req *http.Request) {
+	req.Header.Set("Authorization", fmt.Sprintf("Splunk %s", a.SessionKey))
+}
+
+// TokenAuth uses a splunk authentication token
+type TokenAuth struct {
+	Token string
+}
+
+func (a TokenAuth) Authenticate(req *http.Request) {
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", a.Token))
+}
+
+// BasicAuth sends the username and password with every request
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (a BasicAuth) Authenticate(req *http.Request) { req.SetBasicAuth(a.Username, a.Password) }
+
+// authenticator returns c.Auth if set, else the Authenticator for the
+// profile's AuthType. It is built per request since the session may be
+// renewed
+func (c *Client) authenticator() Authenticator {
+	if c.Auth != nil {
+		return c.Auth
+	}
+	switch c.AuthType {
+	case AuthToken:
+		return TokenAuth{Token: c.Token}
+	case AuthBasic:
+		return BasicAuth{Username: c.Username, Password: c.Password}
+	}
+	return SessionKeyAuth{SessionKey: c.SessionID}
+}
+
+// usesSession reports whether requests are authenticated with the
+// session key, the only credential RenewSessionID can renew
+func (c *Client) usesSession() bool {
+	return c.Auth == nil && (c.AuthType == "" || c.AuthType == AuthSession)
+}
+
+// UseToken switches the client to token authentication. TokenID is
+// cleared, set it after if the token's id is known
+func (c *Client) UseToken(token string) {
+	c.AuthType, c.Token, c.TokenID = AuthToken, token, ""
+}
+
+// UseBasicAuth switches the client to HTTP basic authentication
+func (c *Client) UseBasicAuth(username, password string) {
+	c.AuthType, c.Username, c.Password = AuthBasic, username, password
+}
+
+// CurrentUser returns the name of the user the client authenticates as
+func (c *Client) CurrentUser() (string, error) {
+	return c.CurrentUserContext(context.Background())
+}
+
+func (c *Client) CurrentUserContext(ctx context.Context) (string, error) {
+	r, err := c.callOK(ctx, request{method: "GET", path: "/services/authentication/current-context", data: jsonParams()})
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Entry []struct {
+			Content struct {
+				Username string `json:"username"`
+			} `json:"content"`
+		} `json:"entry"`
+	}
+	err = json.Unmarshal(r.Body, &resp)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Entry) == 0 {
+		return "", fmt.Errorf("no entry in current-context response")
+	}
+	return resp.Entry[0].Content.Username, nil
+}
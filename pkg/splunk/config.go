@@ -0,0 +1,263 @@
+package splunk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// DefaultProfile is the profile used when none is selected, and the
+// one a single client config file is migrated into
+const DefaultProfile = "default"
+
+// Config is the contents of the config file: a client per named profile
+type Config struct {
+	CurrentProfile string             `json:"current_profile"`
+	Profiles       map[string]*Client `json:"profiles"`
+
+	fileloc string
+	legacy  map[string]map[string]string // per profile, see legacySearches
+}
+
+func NewConfig() *Config { return &Config{Profiles: make(map[string]*Client)} }
+
+func (c *Config) ToJSON() string {
+	byts, _ := json.MarshalIndent(c, "", "    ")
+	return string(byts)
+}
+
+// SaveTo writes the config file, readable only by its owner since it
+// may hold session keys. Searches kept in the file by older versions are
+// moved to the history first
+func (c *Config) SaveTo(fileloc string) error {
+	err := c.importSearches(fileloc)
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomic(fileloc, []byte(c.ToJSON()), 0600)
+	if err != nil {
+		return err
+	}
+	c.legacy = nil
+	return nil
+}
+
+// LoadConfig reads the config file at fileloc. A file written before
+// profiles existed, holding a single client, is loaded as DefaultProfile
+func LoadConfig(fileloc string) (*Config, error) {
+	byts, err := ioutil.ReadFile(fileloc)
+	if err != nil {
+		return nil, err
+	}
+	var probe map[string]json.RawMessage
+	err = json.Unmarshal(byts, &probe)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := NewConfig()
+	ret.fileloc = fileloc
+	if _, ok := probe["profiles"]; !ok {
+		cli := New("")
+		err = json.Unmarshal(byts, cli)
+		if err != nil {
+			return nil, err
+		}
+		ret.CurrentProfile = DefaultProfile
+		ret.Profiles[DefaultProfile] = cli
+	} else {
+		err = json.Unmarshal(byts, ret)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for name, cli := range ret.Profiles {
+		if cli == nil {
+			cli = New("")
+			ret.Profiles[name] = cli
+		}
+		cli.Profile = name
+		cli.History = OpenHistory(HistoryPath(fileloc))
+	}
+	ret.legacy, err = legacySearches(byts)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// legacySearches returns, per profile, the search IDs kept in the config
+// file before the history existed
+func legacySearches(byts []byte) (map[string]map[string]string, error) {
+	var legacy struct {
+		Searches map[string]string `json:"searches"`
+		Profiles map[string]*struct {
+			Searches map[string]string `json:"searches"`
+		} `json:"profiles"`
+	}
+	err := json.Unmarshal(byts, &legacy)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]map[string]string)
+	if len(legacy.Searches) > 0 {
+		ret[DefaultProfile] = legacy.Searches
+	}
+	for name, p := range legacy.Profiles {
+		if p != nil && len(p.Searches) > 0 {
+			ret[name] = p.Searches
+		}
+	}
+	if len(ret) == 0 {
+		return nil, nil
+	}
+	return ret, nil
+}
+
+// importSearches adds the legacy searches missing from the history next
+// to fileloc. The config file no longer has a place for them, so they
+// are dropped from it once it is saved
+func (c *Config) importSearches(fileloc string) error {
+	if len(c.legacy) == 0 {
+		return nil
+	}
+	h := OpenHistory(HistoryPath(fileloc))
+	entries, err := h.Entries()
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	for _, e := range entries {
+		known[e.SearchID] = true
+	}
+	nextID := 1
+	if len(entries) > 0 {
+		nextID = entries[len(entries)-1].ID + 1
+	}
+	added := false
+	for _, name := range c.ProfileNames() {
+		searches := c.legacy[name]
+		var sids []string
+		for sid := range searches {
+			sids = append(sids, sid)
+		}
+		sort.Strings(sids)
+		for _, sid := range sids {
+			if known[sid] {
+				continue
+			}
+			// when they were submitted is unknown
+			e := HistoryEntry{ID: nextID, SearchID: sid, Search: searches[sid], Profile: name, Addr: c.Profiles[name].Addr}
+			e.Earliest, e.Latest = timeRange(e.Search, nil)
+			entries = append(entries, e)
+			known[sid] = true
+			nextID++
+			added = true
+		}
+	}
+	if !added {
+		return nil
+	}
+	return h.save(entries)
+}
+
+// ProfileNames returns the names of all profiles, sorted
+func (c *Config) ProfileNames() []string {
+	var names []string
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the client for profile `name`, or for the current
+// profile when name is empty, without reading its credential store
+func (c *Config) Lookup(name string) (*Client, error) {
+	if name == "" {
+		name = c.CurrentProfile
+	}
+	if name == "" {
+		name = DefaultProfile
+	}
+	cli, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("no such profile: %s", name)
+	}
+	return cli, nil
+}
+
+// Client is Lookup with the secrets read from the profile's credential
+// store and its connection settings checked
+func (c *Config) Client(name string) (*Client, error) {
+	cli, err := c.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	err = cli.loadCredentials(c.fileloc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credentials of profile %s: %s", cli.Profile, err.Error())
+	}
+	err = cli.checkSettings()
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %s", cli.Profile, err.Error())
+	}
+	return cli, nil
+}
+
+// SetClient stores cli as profile `name`
+func (c *Config) SetClient(name string, cli *Client) {
+	cli.Profile = name
+	c.Profiles[name] = cli
+	if c.CurrentProfile == "" {
+		c.CurrentProfile = name
+	}
+}
+
+// Use makes `name` the current profile
+func (c *Config) Use(name string) error {
+	if _, ok := c.Profiles[name]; !ok {
+		return fmt.Errorf("no such profile: %s", name)
+	}
+	c.CurrentProfile = name
+	return nil
+}
+
+// Remove deletes profile `name` and erases its secrets from its
+// credential store. Removing the current profile leaves no profile
+// selected
+func (c *Config) Remove(name string) error {
+	cli, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("no such profile: %s", name)
+	}
+	if err := cli.eraseCredentials(cli.Store, c.fileloc); err != nil {
+		return fmt.Errorf("unable to erase the credentials of profile %s: %s", name, err.Error())
+	}
+	delete(c.Profiles, name)
+	if c.CurrentProfile == name {
+		c.CurrentProfile = ""
+	}
+	return nil
+}
+
+// LoadProfile loads the client of profile `name` from fileloc. An
+// empty name selects the current profile
+func LoadProfile(fileloc, name string) (*Client, error) {
+	cfg, err := LoadConfig(fileloc)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Client(name)
+}
+
+// loadOrNewConfig is LoadConfig, with an empty config when fileloc does not exist yet
+func loadOrNewConfig(fileloc string) (*Config, error) {
+	cfg, err := LoadConfig(fileloc)
+	if os.IsNotExist(err) {
+		return NewConfig(), nil
+	}
+	return cfg, err
+}
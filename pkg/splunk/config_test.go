@@ -0,0 +1,129 @@
+package splunk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLegacySearches(t *testing.T) {
+	tests := []struct {
+		name, config string
+		// the searches in the history once saved, as sid:profile
+		want []string
+	}{
+		{"single client", `{"addr":"https://sh:8089","searches":{"2":"search earliest=-1h b","1":"search a"}}`,
+			[]string{"0:default", "1:default", "2:default"}},
+		{"profiles", `{"current_profile":"prod","profiles":{"prod":{"searches":{"3":"search c"}},"dev":{"searches":{"0":"search again"}},"ci":{}}}`,
+			[]string{"0:default", "3:prod"}},
+		{"none", `{"current_profile":"prod","profiles":{"prod":{}}}`, []string{"0:default"}},
+	}
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("", "splunk-config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		fileloc := filepath.Join(dir, "splunk")
+		if err := ioutil.WriteFile(fileloc, []byte(tt.config), 0600); err != nil {
+			t.Fatal(err)
+		}
+		h := OpenHistory(HistoryPath(fileloc))
+		if _, err := h.Add(HistoryEntry{SearchID: "0", Profile: DefaultProfile}); err != nil {
+			t.Fatal(err)
+		}
+		before, _ := ioutil.ReadFile(h.Path)
+
+		cfg, err := LoadConfig(fileloc)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if after, _ := ioutil.ReadFile(h.Path); string(after) != string(before) {
+			t.Errorf("%s: LoadConfig wrote the history", tt.name)
+		}
+		if err := cfg.SaveTo(fileloc); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		entries, err := h.Entries()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for i, e := range entries {
+			if e.ID != i+1 {
+				t.Errorf("%s: entry %s numbered %d, want %d", tt.name, e.SearchID, e.ID, i+1)
+			}
+			got = append(got, e.SearchID+":"+e.Profile)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: history %v, want %v", tt.name, got, tt.want)
+		}
+		if byts, _ := ioutil.ReadFile(fileloc); strings.Contains(string(byts), "searches") {
+			t.Errorf("%s: searches left in the config: %s", tt.name, byts)
+		}
+
+		// saving again does not import anything twice
+		cfg, err = LoadConfig(fileloc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.SaveTo(fileloc); err != nil {
+			t.Fatal(err)
+		}
+		if again, _ := h.Entries(); len(again) != len(entries) {
+			t.Errorf("%s: %d entries after saving again, want %d", tt.name, len(again), len(entries))
+		}
+	}
+}
+
+func TestClearKnownSearches(t *testing.T) {
+	var asked []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sid := strings.TrimPrefix(r.URL.Path, "/services/search/jobs/")
+		asked = append(asked, sid)
+		if strings.HasPrefix(sid, "gone") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"entry":[{"content":{"sid":%q,"dispatchState":"DONE"}}]}`, sid)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "splunk-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	h := OpenHistory(filepath.Join(dir, "history"))
+	for _, e := range []HistoryEntry{
+		{SearchID: "alive", Profile: "prod", Addr: ts.URL},
+		{SearchID: "gone1", Profile: "prod", Addr: ts.URL},
+		{SearchID: "gone2", Profile: "prod", Addr: ts.URL, Status: StatusExpired},
+		{SearchID: "gone3", Profile: "dev", Addr: ts.URL},
+	} {
+		if _, err := h.Add(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &Client{Addr: ts.URL, Profile: "prod", History: h}
+	if err := c.ClearKnownSearches(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"alive", "gone1"}; !reflect.DeepEqual(asked, want) {
+		t.Errorf("asked about %v, want only %v", asked, want)
+	}
+	entries, _ := h.Entries()
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.SearchID+":"+e.Status)
+	}
+	if want := []string{"alive:", "gone1:" + StatusExpired, "gone2:" + StatusExpired, "gone3:"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("history %v, want %v", got, want)
+	}
+}
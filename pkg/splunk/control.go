@@ -0,0 +1,107 @@
+package splunk
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Actions accepted by /services/search/jobs/{sid}/control
+const (
+	ActionCancel   = "cancel"
+	ActionPause    = "pause"
+	ActionUnpause  = "unpause"
+	ActionFinalize = "finalize"
+	ActionTouch    = "touch"
+	ActionSetTTL   = "setttl"
+)
+
+// ControlJob sends `action` to a search job along with `data`, if any
+func (c *Client) ControlJob(ctx context.Context, searchID, action string, data url.Values) error {
+	// curl -H "Authorization: Splunk $SPLUNK_SESSION" https://splunk.sendgrid.net:8089/services/search/jobs/$SEARCH_ID/control -d action=cancel
+	if data == nil {
+		data = url.Values{}
+	}
+	data.Set("output_mode", "json")
+	data.Set("action", action)
+	path := fmt.Sprintf("/services/search/jobs/%s/control", searchID)
+	_, err := c.callOK(ctx, request{method: "POST", path: path, data: data})
+	return err
+}
+
+// CancelJob stops a running search job and removes it from the server
+func (c *Client) CancelJob(searchID string) error {
+	return c.CancelJobContext(context.Background(), searchID)
+}
+
+func (c *Client) CancelJobContext(ctx context.Context, searchID string) error {
+	err := c.ControlJob(ctx, searchID, ActionCancel, nil)
+	if err != nil {
+		return err
+	}
+	if err := c.recordStatus(searchID, StatusCancelled, 0); err != nil {
+		Warnf("unable to record search %s in history: %s", searchID, err.Error())
+	}
+	return nil
+}
+
+// PauseJob suspends a running search job until UnpauseJob
+func (c *Client) PauseJob(searchID string) error {
+	return c.PauseJobContext(context.Background(), searchID)
+}
+
+func (c *Client) PauseJobContext(ctx context.Context, searchID string) error {
+	return c.ControlJob(ctx, searchID, ActionPause, nil)
+}
+
+// UnpauseJob resumes a paused search job
+func (c *Client) UnpauseJob(searchID string) error {
+	return c.UnpauseJobContext(context.Background(), searchID)
+}
+
+func (c *Client) UnpauseJobContext(ctx context.Context, searchID string) error {
+	return c.ControlJob(ctx, searchID, ActionUnpause, nil)
+}
+
+// FinalizeJob stops a search job, keeping the results found so far
+func (c *Client) FinalizeJob(searchID string) error {
+	return c.FinalizeJobContext(context.Background(), searchID)
+}
+
+func (c *Client) FinalizeJobContext(ctx context.Context, searchID string) error {
+	return c.ControlJob(ctx, searchID, ActionFinalize, nil)
+}
+
+// TouchJob restarts the countdown of a search job's time to live
+func (c *Client) TouchJob(searchID string) error {
+	return c.TouchJobContext(context.Background(), searchID)
+}
+
+func (c *Client) TouchJobContext(ctx context.Context, searchID string) error {
+	return c.ControlJob(ctx, searchID, ActionTouch, nil)
+}
+
+// SetJobTTL keeps a search job and its results for `ttl` from now
+func (c *Client) SetJobTTL(searchID string, ttl time.Duration) error {
+	return c.SetJobTTLContext(context.Background(), searchID, ttl)
+}
+
+func (c *Client) SetJobTTLContext(ctx context.Context, searchID string, ttl time.Duration) error {
+	data := url.Values{}
+	data.Set("ttl", strconv.Itoa(int(ttl/time.Second)))
+	return c.ControlJob(ctx, searchID, ActionSetTTL, data)
+}
+
+// DeleteJob removes a search job and its results from the server
+func (c *Client) DeleteJob(searchID string) error {
+	return c.DeleteJobContext(context.Background(), searchID)
+}
+
+func (c *Client) DeleteJobContext(ctx context.Context, searchID string) error {
+	// curl -H "Authorization: Splunk $SPLUNK_SESSION" -X DELETE https://splunk.sendgrid.net:8089/services/search/jobs/$SEARCH_ID
+	path := fmt.Sprintf("/services/search/jobs/%s", searchID)
+	_, err := c.callOK(ctx, request{method: "DELETE", path: path, data: jsonParams()})
+	return err
+}
@@ -0,0 +1,351 @@
+package splunk
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// CredentialStore keeps secrets such as session keys out of the config
+// file. Keys look like "<profile>/session_id"
+type CredentialStore interface {
+	Get(key string) (string, error)
+	Store(key, secret string) error
+	Erase(key string) error
+}
+
+// ErrCredentialNotFound is returned by CredentialStore.Get for unknown keys
+var ErrCredentialNotFound = fmt.Errorf("credential not found")
+
+// PassphraseFunc supplies the passphrase of encrypted credential
+// stores. By default it reads SPLUNK_PASSPHRASE
+var PassphraseFunc = func() (string, error) {
+	if p := os.Getenv("SPLUNK_PASSPHRASE"); p != "" {
+		return p, nil
+	}
+	return "", fmt.Errorf("no passphrase for the encrypted credential store: set SPLUNK_PASSPHRASE")
+}
+
+// OpenCredentialStore returns the store described by `spec`, the
+// credential_store setting of a profile:
+//
+//	"" or "config"  secrets stay in the config file
+//	"file"          <config>.credentials, readable only by the owner
+//	"encrypted"     <config>.credentials.enc, encrypted with a passphrase
+//	"helper:<name>" an external helper, see HelperStore
+func OpenCredentialStore(spec, configloc string) (CredentialStore, error) {
+	switch {
+	case spec == "" || spec == "config":
+		return nil, nil
+	case spec == "file":
+		return &FileStore{Path: configloc + ".credentials"}, nil
+	case spec == "encrypted":
+		return &EncryptedFileStore{Path: configloc + ".credentials.enc", Passphrase: PassphraseFunc}, nil
+	case strings.HasPrefix(spec, "helper:"):
+		return &HelperStore{Helper: strings.TrimPrefix(spec, "helper:")}, nil
+	}
+	return nil, fmt.Errorf("unknown credential store %q: must be config, file, encrypted or helper:<name>", spec)
+}
+
+// writeFileAtomic replaces fileloc with data so that readers never see
+// a partially written file
+func writeFileAtomic(fileloc string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(fileloc), "."+filepath.Base(fileloc)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fileloc)
+}
+
+// FileStore keeps secrets in a JSON file with 0600 permissions
+type FileStore struct {
+	Path string
+}
+
+func (f *FileStore) load() (map[string]string, error) {
+	secrets := make(map[string]string)
+	byts, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(byts, &secrets)
+	return secrets, err
+}
+
+func (f *FileStore) save(secrets map[string]string) error {
+	byts, _ := json.MarshalIndent(secrets, "", "    ")
+	return writeFileAtomic(f.Path, byts, 0600)
+}
+
+func (f *FileStore) Get(key string) (string, error) {
+	secrets, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	secret, ok := secrets[key]
+	if !ok {
+		return "", ErrCredentialNotFound
+	}
+	return secret, nil
+}
+
+func (f *FileStore) Store(key, secret string) error {
+	secrets, err := f.load()
+	if err != nil {
+		return err
+	}
+	secrets[key] = secret
+	return f.save(secrets)
+}
+
+func (f *FileStore) Erase(key string) error {
+	secrets, err := f.load()
+	if err != nil {
+		return err
+	}
+	delete(secrets, key)
+	return f.save(secrets)
+}
+
+const (
+	pbkdf2Iterations = 200000
+	saltSize         = 16
+)
+
+// EncryptedFileStore keeps secrets in a file encrypted with AES-256-GCM
+// under a key derived from a passphrase. The key is derived once and
+// kept for the lifetime of the store, which re-encrypts under the same
+// salt with a fresh nonce
+type EncryptedFileStore struct {
+	Path       string
+	Passphrase func() (string, error)
+
+	passphrase string
+	salt, key  []byte
+}
+
+type encryptedFile struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+func (e *EncryptedFileStore) gcm(salt []byte) (cipher.AEAD, error) {
+	if e.passphrase == "" {
+		p, err := e.Passphrase()
+		if err != nil {
+			return nil, err
+		}
+		e.passphrase = p
+	}
+	if e.key == nil || !bytes.Equal(salt, e.salt) {
+		e.salt, e.key = salt, pbkdf2.Key([]byte(e.passphrase), salt, pbkdf2Iterations, 32, sha256.New)
+	}
+	block, err := aes.NewCipher(e.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (e *EncryptedFileStore) load() (map[string]string, error) {
+	secrets := make(map[string]string)
+	byts, err := ioutil.ReadFile(e.Path)
+	if os.IsNotExist(err) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ef encryptedFile
+	err = json.Unmarshal(byts, &ef)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := e.gcm(ef.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, ef.Nonce, ef.Data, nil)
+	if err != nil {
+		e.passphrase, e.salt, e.key = "", nil, nil
+		return nil, fmt.Errorf("unable to decrypt %s: wrong passphrase?", e.Path)
+	}
+	err = json.Unmarshal(plain, &secrets)
+	return secrets, err
+}
+
+func (e *EncryptedFileStore) save(secrets map[string]string) error {
+	plain, _ := json.Marshal(secrets)
+	ef := encryptedFile{Salt: e.salt}
+	if ef.Salt == nil {
+		ef.Salt = make([]byte, saltSize)
+		if _, err := rand.Read(ef.Salt); err != nil {
+			return err
+		}
+	}
+	gcm, err := e.gcm(ef.Salt)
+	if err != nil {
+		return err
+	}
+	ef.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(ef.Nonce); err != nil {
+		return err
+	}
+	ef.Data = gcm.Seal(nil, ef.Nonce, plain, nil)
+	byts, _ := json.MarshalIndent(ef, "", "    ")
+	return writeFileAtomic(e.Path, byts, 0600)
+}
+
+func (e *EncryptedFileStore) Get(key string) (string, error) {
+	secrets, err := e.load()
+	if err != nil {
+		return "", err
+	}
+	secret, ok := secrets[key]
+	if !ok {
+		return "", ErrCredentialNotFound
+	}
+	return secret, nil
+}
+
+func (e *EncryptedFileStore) Store(key, secret string) error {
+	secrets, err := e.load()
+	if err != nil {
+		return err
+	}
+	secrets[key] = secret
+	return e.save(secrets)
+}
+
+func (e *EncryptedFileStore) Erase(key string) error {
+	secrets, err := e.load()
+	if err != nil {
+		return err
+	}
+	delete(secrets, key)
+	return e.save(secrets)
+}
+
+// HelperStore delegates to an external program, in the spirit of git's
+// credential helpers. The helper is run with one of the actions get,
+// store or erase as its last argument and reads attribute lines from
+// stdin, terminated by a blank line:
+//
+//	key=<key>
+//	secret=<secret>   (store only)
+//
+// For get it prints "secret=<secret>", or nothing if the key is unknown.
+//
+// A Helper of "pass" runs `splunk-credential-pass` from the PATH, an
+// absolute path is run as is, and one starting with "!" is run as a
+// shell command with the action appended, as git does
+type HelperStore struct {
+	Helper string
+}
+
+func (h *HelperStore) command(action string) *exec.Cmd {
+	switch {
+	case strings.HasPrefix(h.Helper, "!"):
+		return exec.Command("/bin/sh", "-c", strings.TrimPrefix(h.Helper, "!")+" "+action)
+	case filepath.IsAbs(h.Helper):
+		return exec.Command(h.Helper, action)
+	}
+	return exec.Command("splunk-credential-"+h.Helper, action)
+}
+
+func (h *HelperStore) run(action string, attrs ...string) ([]byte, error) {
+	cmd := h.command(action)
+	cmd.Stdin = strings.NewReader(strings.Join(attrs, "\n") + "\n\n")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("credential helper %s %s: %s", h.Helper, action, err.Error())
+	}
+	return out, nil
+}
+
+func (h *HelperStore) Get(key string) (string, error) {
+	out, err := h.run("get", "key="+key)
+	if err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "secret=") {
+			return strings.TrimPrefix(line, "secret="), nil
+		}
+	}
+	return "", ErrCredentialNotFound
+}
+
+func (h *HelperStore) Store(key, secret string) error {
+	_, err := h.run("store", "key="+key, "secret="+secret)
+	return err
+}
+
+func (h *HelperStore) Erase(key string) error {
+	_, err := h.run("erase", "key="+key)
+	return err
+}
+
+// UseCredentialStore moves the client's secrets to the store described
+// by spec, see OpenCredentialStore, when it is next saved. They are then
+// erased from the previous store
+func (c *Client) UseCredentialStore(spec string) {
+	if spec == "config" {
+		spec = ""
+	}
+	if c.Store != spec && c.movedFrom == "" {
+		c.movedFrom = c.Store
+	}
+	c.Store, c.Credentials, c.stored = spec, nil, nil
+}
+
+// eraseCredentials erases the profile's secrets from the store described
+// by spec
+func (c *Client) eraseCredentials(spec, configloc string) error {
+	store, err := OpenCredentialStore(spec, configloc)
+	if err != nil || store == nil {
+		return err
+	}
+	for name := range c.secrets() {
+		err = store.Erase(c.credentialKey(name))
+		if err != nil && err != ErrCredentialNotFound {
+			return err
+		}
+	}
+	return nil
+}
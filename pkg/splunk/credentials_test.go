@@ -0,0 +1,101 @@
+package splunk
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "splunk-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials")
+
+	prompts := 0
+	store := &EncryptedFileStore{Path: path, Passphrase: func() (string, error) {
+		prompts++
+		return "correct horse", nil
+	}}
+	if _, err := store.Get("prod"); err != ErrCredentialNotFound {
+		t.Fatalf("Get before Store = %v, want ErrCredentialNotFound", err)
+	}
+	if err := store.Store("prod", "session-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Store("dev", "session-2"); err != nil {
+		t.Fatal(err)
+	}
+	salt := store.salt
+	for key, want := range map[string]string{"prod": "session-1", "dev": "session-2"} {
+		if got, err := store.Get(key); err != nil || got != want {
+			t.Errorf("Get(%q) = %q, %v, want %q", key, got, err, want)
+		}
+	}
+	if err := store.Erase("dev"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("dev"); err != ErrCredentialNotFound {
+		t.Errorf("Get after Erase = %v, want ErrCredentialNotFound", err)
+	}
+	if prompts != 1 || string(store.salt) != string(salt) {
+		t.Errorf("asked for the passphrase %d times and changed the salt, want the key derived once", prompts)
+	}
+
+	byts, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(byts), "session-1") {
+		t.Errorf("secret stored in plaintext: %s", byts)
+	}
+
+	// a fresh store, as the next command would open
+	tests := []struct {
+		name       string
+		passphrase string
+		tamper     func(ef *encryptedFile)
+		err        string
+	}{
+		{"reopened", "correct horse", nil, ""},
+		{"wrong passphrase", "battery staple", nil, "wrong passphrase"},
+		{"tampered data", "correct horse", func(ef *encryptedFile) { ef.Data[0] ^= 1 }, "unable to decrypt"},
+		{"tampered nonce", "correct horse", func(ef *encryptedFile) { ef.Nonce[0] ^= 1 }, "unable to decrypt"},
+		{"tampered salt", "correct horse", func(ef *encryptedFile) { ef.Salt[0] ^= 1 }, "unable to decrypt"},
+	}
+	for _, tt := range tests {
+		fileloc := path
+		if tt.tamper != nil {
+			var ef encryptedFile
+			if err := json.Unmarshal(byts, &ef); err != nil {
+				t.Fatal(err)
+			}
+			tt.tamper(&ef)
+			tampered, _ := json.Marshal(ef)
+			fileloc = filepath.Join(dir, "tampered")
+			if err := ioutil.WriteFile(fileloc, tampered, 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		passphrase := tt.passphrase
+		s := &EncryptedFileStore{Path: fileloc, Passphrase: func() (string, error) { return passphrase, nil }}
+		got, err := s.Get("prod")
+		if tt.err == "" {
+			if err != nil || got != "session-1" {
+				t.Errorf("%s: Get = %q, %v, want session-1", tt.name, got, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: Get = %q, %v, want an error containing %q", tt.name, got, err, tt.err)
+		}
+		if s.key != nil || s.passphrase != "" {
+			t.Errorf("%s: kept the key of a passphrase that failed", tt.name)
+		}
+	}
+}
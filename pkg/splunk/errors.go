@@ -0,0 +1,104 @@
+package splunk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrAuth matches, through errors.Is, the errors of calls splunk
+// rejected as not properly authenticated
+var ErrAuth = fmt.Errorf("call not properly authenticated")
+
+// APIError is a non 2xx response from splunk along with the messages
+// it gave, e.g. {"messages":[{"type":"ERROR","text":"Unknown sid."}]}
+type APIError struct {
+	Method     string
+	Endpoint   string // e.g. /services/search/jobs
+	StatusCode int
+	Messages   []Message
+	Body       []byte // the raw response, for those without messages
+}
+
+func newAPIError(method, endpoint string, r Response) *APIError {
+	e := &APIError{Method: method, Endpoint: endpoint, StatusCode: r.StatusCode, Body: r.Body}
+	var payload struct {
+		Messages []Message `json:"messages"`
+	}
+	if json.Unmarshal(r.Body, &payload) == nil {
+		e.Messages = payload.Messages
+	}
+	return e
+}
+
+// Text joins the text of the messages splunk gave
+func (e *APIError) Text() string {
+	var texts []string
+	for _, m := range e.Messages {
+		texts = append(texts, m.Text)
+	}
+	return strings.Join(texts, "; ")
+}
+
+func (e *APIError) Error() string {
+	msg := e.Text()
+	if msg == "" {
+		msg = strings.TrimSpace(string(e.Body))
+		if len(msg) > 512 {
+			msg = msg[:512] + "..."
+		}
+	}
+	ret := fmt.Sprintf("%s %s: %d %s", e.Method, e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode))
+	if msg != "" {
+		ret += ": " + msg
+	}
+	return ret
+}
+
+// Is makes errors.Is(err, ErrAuth) hold for 401 responses
+func (e *APIError) Is(target error) bool {
+	return target == ErrAuth && e.StatusCode == http.StatusUnauthorized
+}
+
+func asAPIError(err error) (*APIError, bool) {
+	var e *APIError
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+// IsAuth reports whether err is due to missing, expired or invalid credentials
+func IsAuth(err error) bool { return errors.Is(err, ErrAuth) }
+
+// IsCanceled reports whether err is due to a cancelled context, e.g. on
+// an interrupt
+func IsCanceled(err error) bool { return errors.Is(err, context.Canceled) }
+
+// IsNotFound reports whether err is a 404, e.g. for an unknown search ID
+func IsNotFound(err error) bool {
+	e, ok := asAPIError(err)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// IsQuotaExceeded reports whether splunk refused a search because a
+// concurrency or disk quota of the user or instance was reached
+func IsQuotaExceeded(err error) bool {
+	e, ok := asAPIError(err)
+	if !ok {
+		return false
+	}
+	text := strings.ToLower(e.Text())
+	return strings.Contains(text, "quota") || strings.Contains(text, "maximum number of concurrent")
+}
+
+// IsSyntaxError reports whether splunk rejected a search it could not parse
+func IsSyntaxError(err error) bool {
+	e, ok := asAPIError(err)
+	if !ok || e.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	text := e.Text()
+	return strings.Contains(text, "Error in '") || strings.Contains(text, "Unknown search command") || strings.Contains(text, "Unable to parse")
+}
@@ -0,0 +1,118 @@
+package splunk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+)
+
+// ExportRow is a single row streamed back by the export endpoint.
+// Preview rows may be superseded by later rows at the same offset
+type ExportRow struct {
+	Preview  bool      `json:"preview"`
+	Offset   int       `json:"offset"`
+	LastRow  bool      `json:"lastrow"`
+	Result   Result    `json:"result"`
+	Messages []Message `json:"messages"`
+}
+
+// ExportStream decodes rows from an export response one at a time so
+// that memory use does not grow with the size of the result set
+type ExportStream struct {
+	body io.ReadCloser
+	dec  *json.Decoder
+	row  ExportRow
+	err  error
+}
+
+// Next advances to the next row. It returns false when the stream is
+// exhausted or an error occurred; check Err to tell them apart
+func (s *ExportStream) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	for {
+		var row ExportRow
+		err := s.dec.Decode(&row)
+		if err == io.EOF {
+			return false
+		}
+		if err != nil {
+			s.err = err
+			return false
+		}
+		for _, m := range row.Messages {
+			if m.Type == "FATAL" || m.Type == "ERROR" {
+				s.err = fmt.Errorf("export failed: %s", m.String())
+				return false
+			}
+		}
+		if row.Result == nil {
+			// message only rows carry nothing else
+			continue
+		}
+		s.row = row
+		return true
+	}
+}
+
+// Row returns the row read by the last call to Next
+func (s *ExportStream) Row() ExportRow { return s.row }
+
+// Buffered reports whether part of the next row was already received,
+// so that Next does not start by waiting for splunk. Callers flush their
+// output when it is false to show rows as they arrive
+func (s *ExportStream) Buffered() bool {
+	r := s.dec.Buffered()
+	var b [1]byte
+	for {
+		if n, _ := r.Read(b[:]); n == 0 {
+			return false
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return true
+		}
+	}
+}
+
+// Err returns the first error encountered while reading the stream
+func (s *ExportStream) Err() error { return s.err }
+
+// Close releases the underlying connection. Closing before the stream
+// is exhausted stops the search on the server
+func (s *ExportStream) Close() error { return s.body.Close() }
+
+// Export runs `search` against the streaming export endpoint and
+// returns the rows as splunk produces them. The search stops when ctx
+// is cancelled or the stream is closed
+func (c *Client) Export(ctx context.Context, search string, opts ...Option) (*ExportStream, error) {
+	// curl -H "Authorization: Splunk $SPLUNK_SESSION"
+	//      https://splunk.sendgrid.net:8089/services/search/jobs/export
+	//      -d output_mode=json
+	//      -d search='search earliest=-4h event=processed'
+	data := url.Values{}
+	for _, opt := range opts {
+		opt(data)
+	}
+	data.Set("output_mode", "json")
+	data.Set("search", search)
+	resp, err := c.send(ctx, request{method: "POST", path: "/services/search/jobs/export", data: data, stream: true})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		ret := Response{StatusCode: resp.StatusCode}
+		ret.Body, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, newAPIError("POST", "/services/search/jobs/export", ret)
+	}
+	return &ExportStream{body: resp.Body, dec: json.NewDecoder(resp.Body)}, nil
+}
@@ -0,0 +1,199 @@
+package splunk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// HistoryEntry records a submitted search and what became of it
+type HistoryEntry struct {
+	ID          int       `json:"id"` // stable number, e.g. for `splunk history rerun`
+	SearchID    string    `json:"sid"`
+	Search      string    `json:"search"`
+	Profile     string    `json:"profile,omitempty"`
+	Addr        string    `json:"addr,omitempty"`
+	SubmittedAt time.Time `json:"submitted_at"`
+	Earliest    string    `json:"earliest,omitempty"` // as given, e.g. -4h@h
+	Latest      string    `json:"latest,omitempty"`
+	Status      string    `json:"status,omitempty"` // the last dispatch state seen, see StatusExpired
+	ResultCount int       `json:"result_count"`
+	Tags        []string  `json:"tags,omitempty"`
+}
+
+// StatusExpired marks history entries whose job no longer exists on the
+// server, see ClearKnownSearches
+const StatusExpired = "EXPIRED"
+
+// StatusCancelled marks history entries whose job was cancelled
+const StatusCancelled = "CANCELLED"
+
+// HasTag reports whether the entry carries `tag`
+func (e HistoryEntry) HasTag(tag string) bool {
+	for _, t := range e.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// History is the local record of submitted searches, kept as one JSON
+// object per line so that submitting a search only appends
+type History struct {
+	Path string
+}
+
+// OpenHistory returns the history kept at path, which is created when
+// the first search is recorded
+func OpenHistory(path string) *History { return &History{Path: path} }
+
+// HistoryPath is where the history of the profiles in the config file
+// at configloc is kept
+func HistoryPath(configloc string) string { return configloc + ".history" }
+
+// Entries returns every entry, oldest first
+func (h *History) Entries() ([]HistoryEntry, error) {
+	byts, err := ioutil.ReadFile(h.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ret []HistoryEntry
+	scanner := bufio.NewScanner(bytes.NewReader(byts))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e HistoryEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", h.Path, n, err.Error())
+		}
+		ret = append(ret, e)
+	}
+	return ret, scanner.Err()
+}
+
+// Get returns the entry with search ID `sid`
+func (h *History) Get(sid string) (HistoryEntry, bool, error) {
+	entries, err := h.Entries()
+	if err != nil {
+		return HistoryEntry{}, false, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].SearchID == sid {
+			return entries[i], true, nil
+		}
+	}
+	return HistoryEntry{}, false, nil
+}
+
+// Add appends `e`, numbering it after the last entry
+func (h *History) Add(e HistoryEntry) (HistoryEntry, error) {
+	entries, err := h.Entries()
+	if err != nil {
+		return e, err
+	}
+	e.ID = 1
+	if len(entries) > 0 {
+		e.ID = entries[len(entries)-1].ID + 1
+	}
+	byts, _ := json.Marshal(e)
+	f, err := os.OpenFile(h.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return e, err
+	}
+	_, err = f.Write(append(byts, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return e, err
+}
+
+// Update calls fn on the entry of `sid` and saves it if fn returns true
+func (h *History) Update(sid string, fn func(e *HistoryEntry) bool) error {
+	entries, err := h.Entries()
+	if err != nil {
+		return err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].SearchID != sid {
+			continue
+		}
+		if !fn(&entries[i]) {
+			return nil
+		}
+		return h.save(entries)
+	}
+	return nil
+}
+
+func (h *History) save(entries []HistoryEntry) error {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		byts, _ := json.Marshal(e)
+		buf.Write(byts)
+		buf.WriteByte('\n')
+	}
+	return writeFileAtomic(h.Path, buf.Bytes(), 0600)
+}
+
+// Tag adds tags to the entry of `sid`
+func (h *History) Tag(sid string, tags ...string) error {
+	return h.Update(sid, func(e *HistoryEntry) bool {
+		changed := false
+		for _, t := range tags {
+			if t != "" && !e.HasTag(t) {
+				e.Tags = append(e.Tags, t)
+				changed = true
+			}
+		}
+		return changed
+	})
+}
+
+// timeRange returns the time range of a search, from the earliest_time
+// and latest_time parameters or else the earliest= and latest= modifiers
+func timeRange(search string, params map[string][]string) (earliest, latest string) {
+	earliest, latest = InlineTimeRange(search)
+	if v := params["earliest_time"]; len(v) > 0 {
+		earliest = v[0]
+	}
+	if v := params["latest_time"]; len(v) > 0 {
+		latest = v[0]
+	}
+	return earliest, latest
+}
+
+// recordSearch adds a newly submitted search to the client's history
+func (c *Client) recordSearch(sid, search string, params map[string][]string) error {
+	if c.History == nil {
+		return nil
+	}
+	e := HistoryEntry{SearchID: sid, Search: search, Profile: c.Profile, Addr: c.Addr, SubmittedAt: time.Now()}
+	e.Earliest, e.Latest = timeRange(search, params)
+	_, err := c.History.Add(e)
+	return err
+}
+
+// recordStatus keeps the final state of a job in the client's history
+func (c *Client) recordStatus(sid, state string, resultCount int) error {
+	if c.History == nil {
+		return nil
+	}
+	return c.History.Update(sid, func(e *HistoryEntry) bool {
+		if e.Status == state && e.ResultCount == resultCount {
+			return false
+		}
+		e.Status, e.ResultCount = state, resultCount
+		return true
+	})
+}
@@ -0,0 +1,274 @@
+package splunk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dispatch states reported by splunk for a search job
+const (
+	DispatchQueued     = "QUEUED"
+	DispatchParsing    = "PARSING"
+	DispatchRunning    = "RUNNING"
+	DispatchPaused     = "PAUSED"
+	DispatchFinalizing = "FINALIZING"
+	DispatchFailed     = "FAILED"
+	DispatchDone       = "DONE"
+)
+
+// Message is a single message attached to a splunk response,
+// e.g. {"type":"FATAL","text":"Error in 'search' command: ..."}
+type Message struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func (m Message) String() string { return fmt.Sprintf("%s: %s", m.Type, m.Text) }
+
+// JobStatus is the typed content of a /services/search/jobs/{sid} entry
+type JobStatus struct {
+	SearchID      string    `json:"sid"`
+	DispatchState string    `json:"dispatchState"`
+	DoneProgress  float64   `json:"doneProgress"`
+	IsDone        bool      `json:"isDone"`
+	IsFailed      bool      `json:"isFailed"`
+	IsFinalized   bool      `json:"isFinalized"`
+	IsPaused      bool      `json:"isPaused"`
+	EventCount    int       `json:"eventCount"`
+	ResultCount   int       `json:"resultCount"`
+	ScanCount     int       `json:"scanCount"`
+	RunDuration   float64   `json:"runDuration"`
+	DiskUsage     int64     `json:"diskUsage"`
+	TTL           int       `json:"ttl"`
+	EarliestTime  time.Time `json:"earliestTime"`
+	LatestTime    time.Time `json:"latestTime"`
+	Messages      []Message `json:"messages"`
+}
+
+// Finished reports whether the job has reached a terminal state
+func (s JobStatus) Finished() bool {
+	return s.IsDone || s.IsFailed || s.DispatchState == DispatchDone || s.DispatchState == DispatchFailed
+}
+
+// Err returns an error built from the job's messages if the job failed
+func (s JobStatus) Err() error {
+	if !s.IsFailed && s.DispatchState != DispatchFailed {
+		return nil
+	}
+	var msgs []string
+	for _, m := range s.Messages {
+		msgs = append(msgs, m.String())
+	}
+	if len(msgs) == 0 {
+		return fmt.Errorf("search job %s failed", s.SearchID)
+	}
+	return fmt.Errorf("search job %s failed: %s", s.SearchID, strings.Join(msgs, "; "))
+}
+
+// UnmarshalJSON tolerates the empty time strings splunk returns for
+// jobs that have not started scanning yet
+func (s *JobStatus) UnmarshalJSON(byts []byte) error {
+	type status JobStatus
+	var raw struct {
+		status
+		EarliestTime string `json:"earliestTime"`
+		LatestTime   string `json:"latestTime"`
+	}
+	err := json.Unmarshal(byts, &raw)
+	if err != nil {
+		return err
+	}
+	*s = JobStatus(raw.status)
+	s.EarliestTime, err = parseTime(raw.EarliestTime)
+	if err != nil {
+		return err
+	}
+	s.LatestTime, err = parseTime(raw.LatestTime)
+	return err
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// ParseJobStatus decodes the body returned by GetSearchStatus
+func ParseJobStatus(body []byte) (JobStatus, error) {
+	var resp struct {
+		Entry []struct {
+			Name    string    `json:"name"`
+			Content JobStatus `json:"content"`
+		} `json:"entry"`
+	}
+	err := json.Unmarshal(body, &resp)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	if len(resp.Entry) == 0 {
+		return JobStatus{}, fmt.Errorf("no job entry in response")
+	}
+	return resp.Entry[0].Content, nil
+}
+
+// GetJobStatus is GetSearchStatus with the response decoded into a JobStatus
+func (c *Client) GetJobStatus(searchID string) (JobStatus, error) {
+	return c.GetJobStatusContext(context.Background(), searchID)
+}
+
+func (c *Client) GetJobStatusContext(ctx context.Context, searchID string) (JobStatus, error) {
+	r, err := c.GetSearchStatusContext(ctx, searchID)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	if r.StatusCode/100 != 2 {
+		return JobStatus{}, newAPIError("GET", fmt.Sprintf("/services/search/jobs/%s", searchID), r)
+	}
+	status, err := ParseJobStatus(r.Body)
+	if err != nil {
+		return status, err
+	}
+	if status.SearchID == "" {
+		status.SearchID = searchID
+	}
+	if status.Finished() {
+		if err := c.recordStatus(searchID, status.DispatchState, status.ResultCount); err != nil {
+			Warnf("unable to record search %s in history: %s", searchID, err.Error())
+		}
+	}
+	return status, nil
+}
+
+// Job is a search job as listed by ListJobs
+type Job struct {
+	JobStatus
+	Search string `json:"search"`
+	Owner  string `json:"owner"`
+	App    string `json:"app"`
+}
+
+// Sort keys for JobFilter
+const (
+	SortByRuntime   = "runDuration"
+	SortByDiskUsage = "diskUsage"
+)
+
+// JobFilter selects the jobs returned by ListJobs. Empty fields match
+// every job
+type JobFilter struct {
+	Owner  string
+	App    string
+	State  string // a dispatch state such as DispatchRunning
+	Search string // text the SPL must contain, ignoring case
+
+	SortKey  string // e.g. SortByRuntime, splunk's default order if empty
+	SortDesc bool
+
+	// Offset skips that many matching jobs and Count, if not 0, limits
+	// how many are returned, to page through the matches
+	Offset int
+	Count  int
+}
+
+// serverSearch is the `search` parameter that has splunk filter the
+// jobs before they are sent. Its matching is looser than match, e.g. the
+// text of Search may be found in any field, so match still applies
+func (f JobFilter) serverSearch() string {
+	var terms []string
+	for _, t := range [][2]string{{"eai:acl.owner", f.Owner}, {"eai:acl.app", f.App}, {"dispatchState", strings.ToUpper(f.State)}, {"", f.Search}} {
+		if t[1] == "" {
+			continue
+		}
+		term := strconv.Quote(t[1])
+		if t[0] != "" {
+			term = t[0] + "=" + term
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, " ")
+}
+
+func (f JobFilter) match(j Job) bool {
+	switch {
+	case f.Owner != "" && j.Owner != f.Owner:
+		return false
+	case f.App != "" && j.App != f.App:
+		return false
+	case f.State != "" && !strings.EqualFold(j.DispatchState, f.State):
+		return false
+	case f.Search != "" && !strings.Contains(strings.ToLower(j.Search), strings.ToLower(f.Search)):
+		return false
+	}
+	return true
+}
+
+// listJobsPageSize is the number of jobs fetched per request by ListJobs
+const listJobsPageSize = 100
+
+// ListJobs returns the search jobs on the server the client's user can
+// see, including those started from Splunk Web, matching `f`. Splunk
+// filters the jobs and pages stop being fetched once f.Count is reached
+func (c *Client) ListJobs(f JobFilter) ([]Job, error) {
+	return c.ListJobsContext(context.Background(), f)
+}
+
+func (c *Client) ListJobsContext(ctx context.Context, f JobFilter) ([]Job, error) {
+	// curl -H "Authorization: Splunk $SPLUNK_SESSION" -X GET https://splunk.sendgrid.net:8089/services/search/jobs -d output_mode=json -d sort_key=runDuration -d sort_dir=desc
+	var ret []Job
+	skip := f.Offset
+	for offset := 0; ; offset += listJobsPageSize {
+		data := jsonParams()
+		data.Set("offset", strconv.Itoa(offset))
+		data.Set("count", strconv.Itoa(listJobsPageSize))
+		if search := f.serverSearch(); search != "" {
+			data.Set("search", search)
+		}
+		if f.SortKey != "" {
+			data.Set("sort_key", f.SortKey)
+			data.Set("sort_dir", "asc")
+			if f.SortDesc {
+				data.Set("sort_dir", "desc")
+			}
+		}
+		r, err := c.callOK(ctx, request{method: "GET", path: "/services/search/jobs", data: data})
+		if err != nil {
+			return nil, err
+		}
+		var resp struct {
+			Entry []struct {
+				Name string `json:"name"`
+				ACL  struct {
+					App   string `json:"app"`
+					Owner string `json:"owner"`
+				} `json:"acl"`
+				Content JobStatus `json:"content"`
+			} `json:"entry"`
+		}
+		err = json.Unmarshal(r.Body, &resp)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range resp.Entry {
+			j := Job{JobStatus: e.Content, Search: e.Name, Owner: e.ACL.Owner, App: e.ACL.App}
+			if !f.match(j) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			ret = append(ret, j)
+			if f.Count > 0 && len(ret) == f.Count {
+				return ret, nil
+			}
+		}
+		if len(resp.Entry) < listJobsPageSize {
+			return ret, nil
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package splunk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// jobsServer serves `total` jobs of admin in app search, recording the
+// queries of the job listings
+func jobsServer(t *testing.T, total int) (*httptest.Server, *[]url.Values) {
+	var queries []url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		queries = append(queries, q)
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		count, _ := strconv.Atoi(q.Get("count"))
+		type entry struct {
+			Name    string            `json:"name"`
+			ACL     map[string]string `json:"acl"`
+			Content map[string]string `json:"content"`
+		}
+		var resp struct {
+			Entry []entry `json:"entry"`
+		}
+		resp.Entry = []entry{}
+		for i := offset; i < total && i < offset+count; i++ {
+			resp.Entry = append(resp.Entry, entry{Name: fmt.Sprintf("search index=main %d", i),
+				ACL:     map[string]string{"owner": "admin", "app": "search"},
+				Content: map[string]string{"sid": fmt.Sprintf("sid%d", i), "dispatchState": "DONE"}})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &queries
+}
+
+func TestListJobs(t *testing.T) {
+	tests := []struct {
+		name   string
+		total  int
+		filter JobFilter
+		sids   []string
+		pages  int
+		search string // the search parameter sent
+	}{
+		{"limit in the first page", 250, JobFilter{Count: 2}, []string{"sid0", "sid1"}, 1, ""},
+		{"limit in the second page", 250, JobFilter{Offset: 99, Count: 2}, []string{"sid99", "sid100"}, 2, ""},
+		{"all", 150, JobFilter{}, nil, 2, ""},
+		{"filtered by splunk", 5, JobFilter{Owner: "admin", App: "search", State: "done", Search: `main "4"`, Count: 1}, nil, 1,
+			`eai:acl.owner="admin" eai:acl.app="search" dispatchState="DONE" "main \"4\""`},
+		{"filtered again", 5, JobFilter{Owner: "nobody"}, []string{}, 1, `eai:acl.owner="nobody"`},
+	}
+	for _, tt := range tests {
+		ts, queries := jobsServer(t, tt.total)
+		c := &Client{Addr: ts.URL}
+		jobs, err := c.ListJobs(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(*queries) != tt.pages {
+			t.Errorf("%s: fetched %d pages, want %d", tt.name, len(*queries), tt.pages)
+		}
+		if got := (*queries)[0].Get("search"); got != tt.search {
+			t.Errorf("%s: search = %s, want %s", tt.name, got, tt.search)
+		}
+		if tt.sids == nil {
+			continue
+		}
+		var sids []string
+		for _, j := range jobs {
+			sids = append(sids, j.SearchID)
+		}
+		if fmt.Sprint(sids) != fmt.Sprint(tt.sids) {
+			t.Errorf("%s: jobs %v, want %v", tt.name, sids, tt.sids)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package splunk
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReauthFunc renews the session of a client whose session expired,
+// typically by calling RenewSessionID with fresh credentials. When set
+// on Client.Reauth, a request failing with ErrAuth is sent once more
+// after the hook succeeds
+type ReauthFunc func(c *Client) error
+
+// CredentialsFunc supplies a username and password for c. It returns
+// ErrCredentialNotFound when it has nothing to offer
+type CredentialsFunc func(c *Client) (username, password string, err error)
+
+// ReauthWith returns a ReauthFunc that renews the session with the
+// first of `sources` to supply credentials, then calls save, if not
+// nil, to persist the new session
+func ReauthWith(save func(c *Client) error, sources ...CredentialsFunc) ReauthFunc {
+	return func(c *Client) error {
+		for _, source := range sources {
+			username, password, err := source(c)
+			if err == ErrCredentialNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			_, err = c.RenewSessionID(username, password)
+			if err != nil {
+				return err
+			}
+			if save == nil {
+				return nil
+			}
+			return save(c)
+		}
+		return fmt.Errorf("no credentials available")
+	}
+}
+
+// EnvCredentials reads SPLUNK_USERNAME and SPLUNK_PASSWORD. The
+// username defaults to the client's
+func EnvCredentials(c *Client) (string, string, error) {
+	password := os.Getenv("SPLUNK_PASSWORD")
+	if password == "" {
+		return "", "", ErrCredentialNotFound
+	}
+	username := os.Getenv("SPLUNK_USERNAME")
+	if username == "" {
+		username = c.Username
+	}
+	return username, password, nil
+}
+
+// StoreCredentials returns the password the profile keeps, as read
+// from its credential store under "<profile>/password", or from the
+// config file without one, e.g. the password of basic auth
+func StoreCredentials(c *Client) (string, string, error) {
+	if c.Password == "" {
+		return "", "", ErrCredentialNotFound
+	}
+	return c.Username, strings.TrimRight(c.Password, "\n"), nil
+}
@@ -0,0 +1,60 @@
+package splunk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		stored   map[string]string // in the file store
+		password string            // "" for ErrCredentialNotFound
+	}{
+		{"basic auth in the config", `{"profiles":{"prod":{"username":"admin","auth_type":"basic","password":"s3cret"}}}`, nil, "s3cret"},
+		{"file store", `{"profiles":{"prod":{"username":"admin","credential_store":"file"}}}`,
+			map[string]string{"prod/password": "s3cret\n", "dev/password": "other"}, "s3cret"},
+		{"basic auth in the file store", `{"profiles":{"prod":{"username":"admin","auth_type":"basic","credential_store":"file"}}}`,
+			map[string]string{"prod/password": "s3cret"}, "s3cret"},
+		{"none", `{"profiles":{"prod":{"username":"admin","credential_store":"file"}}}`,
+			map[string]string{"dev/password": "other"}, ""},
+	}
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("", "splunk-reauth")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		fileloc := filepath.Join(dir, "splunk")
+		if err := ioutil.WriteFile(fileloc, []byte(tt.config), 0600); err != nil {
+			t.Fatal(err)
+		}
+		store := &FileStore{Path: fileloc + ".credentials"}
+		for k, v := range tt.stored {
+			if err := store.Store(k, v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		cfg, err := LoadConfig(fileloc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cli, err := cfg.Client("prod")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		username, password, err := StoreCredentials(cli)
+		if tt.password == "" {
+			if err != ErrCredentialNotFound {
+				t.Errorf("%s: StoreCredentials = %q, %v, want ErrCredentialNotFound", tt.name, password, err)
+			}
+			continue
+		}
+		if err != nil || username != "admin" || password != tt.password {
+			t.Errorf("%s: StoreCredentials = %q, %q, %v, want admin, %q", tt.name, username, password, err, tt.password)
+		}
+	}
+}
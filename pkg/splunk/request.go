@@ -0,0 +1,121 @@
+package splunk
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// request describes a call to the splunk API so that it can be sent
+// again, e.g. after renewing an expired session
+type request struct {
+	method string
+	path   string     // e.g. /services/search/jobs
+	data   url.Values // the form body of a POST, the query otherwise
+	stream bool       // the response is read for as long as it lasts, see HTTPOptions.Timeout
+}
+
+func (c *Client) newHTTPRequest(ctx context.Context, r request) (*http.Request, error) {
+	urlstr := c.Addr + r.path
+	var req *http.Request
+	var err error
+	if r.method == "POST" {
+		req, err = http.NewRequest(r.method, urlstr, strings.NewReader(r.data.Encode()))
+		if err == nil {
+			req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		req, err = http.NewRequest(r.method, urlstr, nil)
+		if err == nil {
+			req.URL.RawQuery = r.data.Encode()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.authenticator().Authenticate(req)
+	return req.WithContext(ctx), nil
+}
+
+func (c *Client) sendOnce(ctx context.Context, r request) (*http.Response, error) {
+	httpcli, err := c.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	req, err := c.newHTTPRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if r.stream && httpcli.Timeout != 0 {
+		cp := *httpcli
+		cp.Timeout = 0
+		httpcli = &cp
+	}
+	return httpcli.Do(req)
+}
+
+// send issues `r`, retrying transient errors, and returns the response
+// with its body unread. When the session has expired and c.Reauth is
+// set, the session is renewed and `r` is sent once more
+func (c *Client) send(ctx context.Context, r request) (*http.Response, error) {
+	resp, err := c.sendRetry(ctx, r)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || c.Reauth == nil || !c.usesSession() {
+		return resp, err
+	}
+
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	err = c.Reauth(c)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to renew session: %s", ErrAuth, err.Error())
+	}
+	return c.sendRetry(ctx, r)
+}
+
+// call issues `r` and reads the whole response. An *APIError matching
+// ErrAuth is returned along with the response if the call was not
+// authenticated
+func (c *Client) call(ctx context.Context, r request) (Response, error) {
+	var ret Response
+	resp, err := c.send(ctx, r)
+	if err != nil {
+		return ret, err
+	}
+	defer resp.Body.Close()
+	ret.StatusCode = resp.StatusCode
+	ret.Body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return ret, err
+	}
+	if ret.AuthFailed() {
+		return ret, newAPIError(r.method, r.path, ret)
+	}
+	return ret, nil
+}
+
+// callOK is call with any non 2xx response turned into an *APIError
+func (c *Client) callOK(ctx context.Context, r request) (Response, error) {
+	ret, err := c.call(ctx, r)
+	if err != nil {
+		return ret, err
+	}
+	if ret.StatusCode/100 != 2 {
+		return ret, newAPIError(r.method, r.path, ret)
+	}
+	return ret, nil
+}
+
+func jsonParams(opts ...Option) url.Values {
+	data := url.Values{}
+	for _, opt := range opts {
+		opt(data)
+	}
+	if _, ok := data["output_mode"]; !ok {
+		data.Set("output_mode", "json")
+	}
+	return data
+}
@@ -0,0 +1,215 @@
+package splunk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxResultRows is splunk's default limits.conf
+// [restapi] maxresultrows, used when the server's value is unavailable
+const DefaultMaxResultRows = 50000
+
+// Result is a single search result. Values are strings, or lists of
+// strings for multivalue fields
+type Result map[string]interface{}
+
+// Values returns every value of `field`
+func (r Result) Values(field string) []string {
+	switch v := r[field].(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{v}
+	case []interface{}:
+		var ret []string
+		for _, e := range v {
+			ret = append(ret, fmt.Sprint(e))
+		}
+		return ret
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// String returns the value of `field`, with multiple values joined by
+// newlines the way splunk displays them
+func (r Result) String(field string) string { return strings.Join(r.Values(field), "\n") }
+
+func (r Result) Raw() string        { return r.String("_raw") }
+func (r Result) Host() string       { return r.String("host") }
+func (r Result) Source() string     { return r.String("source") }
+func (r Result) SourceType() string { return r.String("sourcetype") }
+
+// Time parses `_time`. The zero time is returned if it is missing or
+// not in splunk's ISO 8601 format
+func (r Result) Time() time.Time {
+	t, _ := parseTime(r.String("_time"))
+	return t
+}
+
+// resultField is a field name in a results page. Older versions of
+// splunk list names as strings, newer ones as {"name": "..."}
+type resultField string
+
+func (f *resultField) UnmarshalJSON(byts []byte) error {
+	var name string
+	if err := json.Unmarshal(byts, &name); err == nil {
+		*f = resultField(name)
+		return nil
+	}
+	var obj struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(byts, &obj); err != nil {
+		return err
+	}
+	*f = resultField(obj.Name)
+	return nil
+}
+
+type resultsPage struct {
+	Fields   []resultField `json:"fields"`
+	Results  []Result      `json:"results"`
+	Messages []Message     `json:"messages"`
+}
+
+// MaxResultRows returns the largest number of results the server
+// returns from a single /results request
+func (c *Client) MaxResultRows() (int, error) {
+	return c.MaxResultRowsContext(context.Background())
+}
+
+func (c *Client) MaxResultRowsContext(ctx context.Context) (int, error) {
+	r, err := c.callOK(ctx, request{method: "GET", path: "/services/properties/limits/restapi/maxresultrows"})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(r.Body)))
+}
+
+// ResultsIterator pages through the results of a finished search job
+// using offset and count
+type ResultsIterator struct {
+	ctx      context.Context
+	c        *Client
+	sid      string
+	pageSize int
+	opts     []Option
+
+	started bool
+	total   int
+	offset  int
+	page    []Result
+	i       int
+	fields  []string
+	err     error
+}
+
+// Results returns an iterator over all results of searchID, fetching
+// `pageSize` rows per request. A pageSize of 0, or one larger than the
+// server allows, uses the server's maxresultrows
+func (c *Client) Results(searchID string, pageSize int, opts ...Option) *ResultsIterator {
+	return c.ResultsContext(context.Background(), searchID, pageSize, opts...)
+}
+
+// ResultsContext is Results with ctx used for every page fetched
+func (c *Client) ResultsContext(ctx context.Context, searchID string, pageSize int, opts ...Option) *ResultsIterator {
+	return &ResultsIterator{ctx: ctx, c: c, sid: searchID, pageSize: pageSize, opts: opts}
+}
+
+func (it *ResultsIterator) start() error {
+	it.started = true
+	status, err := it.c.GetJobStatusContext(it.ctx, it.sid)
+	if err != nil {
+		return err
+	}
+	if err := status.Err(); err != nil {
+		return err
+	}
+	if !status.Finished() {
+		return fmt.Errorf("search job %s is not done: %s", it.sid, status.DispatchState)
+	}
+	it.total = status.ResultCount
+
+	max, err := it.c.MaxResultRowsContext(it.ctx)
+	if IsAuth(err) {
+		return err
+	}
+	if err != nil || max <= 0 {
+		max = DefaultMaxResultRows
+	}
+	if it.pageSize <= 0 || it.pageSize > max {
+		it.pageSize = max
+	}
+	return nil
+}
+
+func (it *ResultsIterator) fetch() error {
+	opts := append([]Option{}, it.opts...)
+	opts = append(opts, WithParam("offset", strconv.Itoa(it.offset)), WithParam("count", strconv.Itoa(it.pageSize)), WithParam("output_mode", "json"))
+	r, err := it.c.GetSearchResultsContext(it.ctx, it.sid, opts...)
+	if err != nil {
+		return err
+	}
+	if r.StatusCode/100 != 2 {
+		return newAPIError("GET", fmt.Sprintf("/services/search/jobs/%s/results", it.sid), r)
+	}
+	var page resultsPage
+	err = json.Unmarshal(r.Body, &page)
+	if err != nil {
+		return fmt.Errorf("unable to decode results at offset %d: %s", it.offset, err.Error())
+	}
+	if it.fields == nil {
+		for _, f := range page.Fields {
+			it.fields = append(it.fields, string(f))
+		}
+	}
+	it.page, it.i = page.Results, 0
+	it.offset += len(page.Results)
+	return nil
+}
+
+// Next advances to the next result, fetching another page when needed.
+// It returns false when all results were read or an error occurred
+func (it *ResultsIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if !it.started {
+		if it.err = it.start(); it.err != nil {
+			return false
+		}
+	}
+	if it.i+1 < len(it.page) {
+		it.i++
+		return true
+	}
+	if it.offset >= it.total {
+		return false
+	}
+	if it.err = it.fetch(); it.err != nil {
+		return false
+	}
+	if len(it.page) == 0 {
+		// results expired or were truncated server side
+		it.err = fmt.Errorf("results for %s ended at offset %d of %d", it.sid, it.offset, it.total)
+		return false
+	}
+	return true
+}
+
+// Result returns the result read by the last call to Next
+func (it *ResultsIterator) Result() Result { return it.page[it.i] }
+
+// Fields returns the field names splunk listed with the first page
+func (it *ResultsIterator) Fields() []string { return it.fields }
+
+// Total returns the job's resultCount once iteration has started
+func (it *ResultsIterator) Total() int { return it.total }
+
+// Err returns the first error encountered during iteration
+func (it *ResultsIterator) Err() error { return it.err }
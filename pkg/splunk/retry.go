@@ -0,0 +1,179 @@
+package splunk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy decides how requests failing with transient errors, such
+// as a search head restarting, are retried. GET and DELETE requests are
+// retried on network errors and on 429, 500, 502, 503 and 504 responses.
+// Other requests, job creation in particular, are only retried when
+// splunk certainly did not act on them: the connection could not be
+// made, or splunk answered 429 or 503. A Retry-After header replaces
+// the backoff, unless it asks to wait longer than MaxBackoff, in which
+// case the request fails at once
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt, 1 disables retries
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// MinBackoff is the delay before the first retry, doubled (see
+	// Multiplier) for every further one up to MaxBackoff
+	MinBackoff Duration `json:"min_backoff,omitempty"`
+	MaxBackoff Duration `json:"max_backoff,omitempty"`
+	Multiplier float64  `json:"multiplier,omitempty"`
+	// Jitter randomizes each delay by up to this fraction of it
+	Jitter float64 `json:"jitter,omitempty"`
+}
+
+// DefaultRetryPolicy is used by clients without a RetryPolicy. Zero
+// fields of a RetryPolicy take their value from it
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MinBackoff:  Duration(500 * time.Millisecond),
+	MaxBackoff:  Duration(30 * time.Second),
+	Multiplier:  2,
+	Jitter:      0.2,
+}
+
+// RetryEvent describes a failed attempt that is about to be retried, see
+// Client.OnRetry
+type RetryEvent struct {
+	Method     string
+	Path       string
+	Attempt    int           // the attempt that failed, starting at 1
+	StatusCode int           // 0 if no response was received
+	Err        error         // the network error, if any
+	Delay      time.Duration // how long until the next attempt
+}
+
+// WithRetryPolicy sets the retry policy of the client
+func WithRetryPolicy(p RetryPolicy) ClientOption {
+	return func(c *Client) { c.Retry = &p }
+}
+
+func (c *Client) retryPolicy() RetryPolicy {
+	if c.Retry == nil {
+		return DefaultRetryPolicy
+	}
+	return c.Retry.WithDefaults()
+}
+
+// WithDefaults returns the policy with its zero fields taken from
+// DefaultRetryPolicy
+func (r RetryPolicy) WithDefaults() RetryPolicy {
+	p := DefaultRetryPolicy
+	if r.MaxAttempts != 0 {
+		p.MaxAttempts = r.MaxAttempts
+	}
+	if r.MinBackoff != 0 {
+		p.MinBackoff = r.MinBackoff
+	}
+	if r.MaxBackoff != 0 {
+		p.MaxBackoff = r.MaxBackoff
+	}
+	if r.Multiplier != 0 {
+		p.Multiplier = r.Multiplier
+	}
+	if r.Jitter != 0 {
+		p.Jitter = r.Jitter
+	}
+	return p
+}
+
+// Delay returns how long to wait after failed attempt n, starting at 1,
+// which received `resp`, nil on network errors. It is false if the
+// response asks to wait longer than MaxBackoff
+func (p RetryPolicy) Delay(n int, resp *http.Response) (time.Duration, bool) {
+	if d, ok := retryAfter(resp); ok {
+		return d, d <= time.Duration(p.MaxBackoff)
+	}
+	return p.backoff(n), true
+}
+
+// backoff returns the delay after failed attempt n, starting at 1
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := float64(p.MinBackoff) * math.Pow(p.Multiplier, float64(n-1))
+	if d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	d += d * p.Jitter * (2*rand.Float64() - 1)
+	return time.Duration(d)
+}
+
+// retryable reports whether the outcome of sending `r` may be retried
+func retryable(ctx context.Context, r request, resp *http.Response, err error) bool {
+	idempotent := r.method == "GET" || r.method == "DELETE"
+	if err != nil {
+		var opErr *net.OpError
+		notSent := errors.As(err, &opErr) && (opErr.Op == "dial" || opErr.Op == "proxyconnect")
+		return ctx.Err() == nil && (notSent || idempotent)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header, in seconds or as a date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if sec, err := strconv.Atoi(v); err == nil && sec >= 0 {
+		return time.Duration(sec) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// sendRetry is sendOnce, retried according to the client's RetryPolicy
+func (c *Client) sendRetry(ctx context.Context, r request) (*http.Response, error) {
+	p := c.retryPolicy()
+	for attempt := 1; ; attempt++ {
+		resp, err := c.sendOnce(ctx, r)
+		if attempt >= p.MaxAttempts || !retryable(ctx, r, resp, err) {
+			return resp, err
+		}
+		delay, ok := p.Delay(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+		ev := RetryEvent{Method: r.method, Path: r.path, Attempt: attempt, Err: err, Delay: delay}
+		if resp != nil {
+			ev.StatusCode = resp.StatusCode
+			io.Copy(ioutil.Discard, resp.Body) // lets the connection be reused
+			resp.Body.Close()
+		}
+		if c.OnRetry != nil {
+			c.OnRetry(ev)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package splunk
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}
+	read := &net.OpError{Op: "read", Err: fmt.Errorf("connection reset by peer")}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		method string
+		status int // 0 for the network error `err`
+		err    error
+		ctx    context.Context
+		want   bool
+	}{
+		{"GET", 503, nil, nil, true},
+		{"GET", 429, nil, nil, true},
+		{"GET", 500, nil, nil, true},
+		{"GET", 502, nil, nil, true},
+		{"GET", 504, nil, nil, true},
+		{"DELETE", 500, nil, nil, true},
+		{"GET", 400, nil, nil, false},
+		{"GET", 401, nil, nil, false},
+		{"GET", 404, nil, nil, false},
+		{"POST", 503, nil, nil, true},
+		{"POST", 429, nil, nil, true},
+		// splunk may have created the job before failing
+		{"POST", 500, nil, nil, false},
+		{"POST", 502, nil, nil, false},
+		{"POST", 504, nil, nil, false},
+		{"GET", 0, read, nil, true},
+		{"POST", 0, read, nil, false},
+		{"POST", 0, dial, nil, true},
+		{"POST", 0, fmt.Errorf("wrapped: %w", dial), nil, true},
+		{"GET", 0, context.Canceled, canceled, false},
+	}
+	for _, tt := range tests {
+		ctx := tt.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		var resp *http.Response
+		if tt.err == nil {
+			resp = &http.Response{StatusCode: tt.status}
+		}
+		if got := retryable(ctx, request{method: tt.method}, resp, tt.err); got != tt.want {
+			t.Errorf("retryable(%s, %d, %v) = %v, want %v", tt.method, tt.status, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDelay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, MinBackoff: Duration(time.Second), MaxBackoff: Duration(5 * time.Second), Multiplier: 2}
+	withHeader := func(v string) *http.Response {
+		return &http.Response{StatusCode: 503, Header: http.Header{"Retry-After": []string{v}}}
+	}
+	tests := []struct {
+		name    string
+		attempt int
+		resp    *http.Response
+		delay   time.Duration
+		ok      bool
+	}{
+		{"first", 1, nil, time.Second, true},
+		{"doubled", 2, nil, 2 * time.Second, true},
+		{"doubled twice", 3, &http.Response{StatusCode: 503}, 4 * time.Second, true},
+		{"capped", 4, nil, 5 * time.Second, true},
+		{"retry after seconds", 1, withHeader("3"), 3 * time.Second, true},
+		{"retry after zero", 3, withHeader("0"), 0, true},
+		{"retry after max", 1, withHeader("5"), 5 * time.Second, true},
+		{"retry after too long", 1, withHeader("86400"), 86400 * time.Second, false},
+		{"retry after past date", 2, withHeader("Mon, 02 Jan 2006 15:04:05 GMT"), 0, true},
+		{"retry after far date", 2, withHeader(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)), -1, false},
+		{"retry after invalid", 2, withHeader("soon"), 2 * time.Second, true},
+		{"retry after negative", 2, withHeader("-1"), 2 * time.Second, true},
+	}
+	for _, tt := range tests {
+		delay, ok := p.Delay(tt.attempt, tt.resp)
+		if ok != tt.ok || (tt.delay >= 0 && delay != tt.delay) {
+			t.Errorf("%s: Delay(%d) = %s, %v, want %s, %v", tt.name, tt.attempt, delay, ok, tt.delay, tt.ok)
+		}
+	}
+
+	p.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		if d, _ := p.Delay(2, nil); d < 1600*time.Millisecond || d > 2400*time.Millisecond {
+			t.Fatalf("Delay(2) with 20%% jitter = %s, want within 1.6s and 2.4s", d)
+		}
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	got := RetryPolicy{MaxAttempts: 1, Jitter: 0.5}.WithDefaults()
+	want := DefaultRetryPolicy
+	want.MaxAttempts, want.Jitter = 1, 0.5
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WithDefaults() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSendRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		statuses []int  // answered in turn, then 200
+		after    string // Retry-After of the failures
+		status   int    // of the final response
+		attempts int
+	}{
+		{"success", "GET", nil, "", 200, 1},
+		{"transient", "GET", []int{503, 500}, "", 200, 3},
+		{"exhausted", "GET", []int{503, 503, 503}, "", 503, 3},
+		{"not retried", "GET", []int{404}, "", 404, 1},
+		{"post not retried", "POST", []int{502}, "", 502, 1},
+		{"retry after", "POST", []int{429}, "0", 200, 2},
+		{"retry after too long", "GET", []int{503}, "86400", 503, 1},
+	}
+	for _, tt := range tests {
+		attempts := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if attempts <= len(tt.statuses) {
+				if tt.after != "" {
+					w.Header().Set("Retry-After", tt.after)
+				}
+				w.WriteHeader(tt.statuses[attempts-1])
+			}
+		}))
+		var events []RetryEvent
+		c := &Client{Addr: ts.URL, OnRetry: func(e RetryEvent) { events = append(events, e) },
+			Retry: &RetryPolicy{MaxAttempts: 3, MinBackoff: Duration(time.Millisecond), MaxBackoff: Duration(time.Second)}}
+		resp, err := c.sendRetry(context.Background(), request{method: tt.method, path: "/services/search/jobs"})
+		ts.Close()
+		if err != nil {
+			t.Errorf("%s: sendRetry: %v", tt.name, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || attempts != tt.attempts || len(events) != tt.attempts-1 {
+			t.Errorf("%s: got %d after %d attempts and %d retry events, want %d after %d attempts",
+				tt.name, resp.StatusCode, attempts, len(events), tt.status, tt.attempts)
+		}
+		for i, e := range events {
+			if e.Attempt != i+1 || e.StatusCode != tt.statuses[i] || e.Path != "/services/search/jobs" {
+				t.Errorf("%s: retry event %+v", tt.name, e)
+			}
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package splunk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SavedSearch is a report or alert kept on the server
+type SavedSearch struct {
+	Name        string `json:"name" yaml:"name"`
+	App         string `json:"app,omitempty" yaml:"app,omitempty"`
+	Owner       string `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Search      string `json:"search" yaml:"search"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+
+	// CronSchedule, e.g. "*/15 * * * *", runs the search on a schedule
+	// when set
+	CronSchedule string `json:"cron_schedule,omitempty" yaml:"cron_schedule,omitempty"`
+
+	// Earliest and Latest are the time range the search is dispatched
+	// with, e.g. -15m and now
+	Earliest string `json:"earliest,omitempty" yaml:"earliest,omitempty"`
+	Latest   string `json:"latest,omitempty" yaml:"latest,omitempty"`
+
+	// Alert makes a scheduled search an alert
+	Alert *Alert `json:"alert,omitempty" yaml:"alert,omitempty"`
+
+	// Params are any other attributes of the saved search, e.g.
+	// action.email.to or dispatch.ttl. Only the parameters of the
+	// alert's actions are read back from the server
+	Params map[string]string `json:"params,omitempty" yaml:"params,omitempty"`
+
+	// content is every attribute of a saved search read from the server
+	content map[string]string
+}
+
+// Alert is the trigger condition and actions of a saved search
+type Alert struct {
+	// Type is "always", "number of events", "number of results",
+	// "number of hosts", "number of sources" or "custom"
+	Type       string   `json:"type,omitempty" yaml:"type,omitempty"`
+	Comparator string   `json:"comparator,omitempty" yaml:"comparator,omitempty"` // e.g. "greater than", for the number of types
+	Threshold  string   `json:"threshold,omitempty" yaml:"threshold,omitempty"`
+	Condition  string   `json:"condition,omitempty" yaml:"condition,omitempty"` // a search over the results, for the custom type
+	Severity   int      `json:"severity,omitempty" yaml:"severity,omitempty"`   // 1 debug to 6 fatal
+	Suppress   string   `json:"suppress,omitempty" yaml:"suppress,omitempty"`   // throttles the alert for this period, e.g. 1h
+	Actions    []string `json:"actions,omitempty" yaml:"actions,omitempty"`     // e.g. email or webhook, configured through Params
+}
+
+// Namespace is the owner and app of a knowledge object such as a saved
+// search. Empty fields match any owner or app
+type Namespace struct {
+	Owner string
+	App   string
+}
+
+// Namespace returns the owner and app the saved search belongs to
+func (s SavedSearch) Namespace() Namespace { return Namespace{Owner: s.Owner, App: s.App} }
+
+// path is `p` under the endpoints of ns, e.g. /servicesNS/-/search/saved/searches
+func (ns Namespace) path(p string) string {
+	owner, app := ns.Owner, ns.App
+	if owner == "" {
+		owner = "-"
+	}
+	if app == "" {
+		app = "-"
+	}
+	return fmt.Sprintf("/servicesNS/%s/%s%s", url.PathEscape(owner), url.PathEscape(app), p)
+}
+
+func savedSearchPath(ns Namespace, name string) string {
+	return ns.path("/saved/searches/" + url.PathEscape(name))
+}
+
+// values are the attributes of the saved search as sent to the server.
+// Every attribute modelled by SavedSearch is included so that an update
+// clears those left empty
+func (s SavedSearch) values() url.Values {
+	data := jsonParams()
+	data.Set("search", s.Search)
+	data.Set("description", s.Description)
+	data.Set("disabled", strconv.FormatBool(s.Disabled))
+	data.Set("is_scheduled", strconv.FormatBool(s.CronSchedule != ""))
+	if s.CronSchedule != "" {
+		data.Set("cron_schedule", s.CronSchedule)
+	}
+	data.Set("dispatch.earliest_time", s.Earliest)
+	data.Set("dispatch.latest_time", s.Latest)
+	a := Alert{Type: "always"}
+	if s.Alert != nil {
+		a = *s.Alert
+	}
+	if a.Type == "" {
+		a.Type = "always"
+	}
+	data.Set("alert_type", a.Type)
+	data.Set("alert_comparator", a.Comparator)
+	data.Set("alert_threshold", a.Threshold)
+	data.Set("alert_condition", a.Condition)
+	if a.Severity != 0 {
+		data.Set("alert.severity", strconv.Itoa(a.Severity))
+	}
+	data.Set("alert.suppress", strconv.FormatBool(a.Suppress != ""))
+	if a.Suppress != "" {
+		data.Set("alert.suppress.period", a.Suppress)
+	}
+	data.Set("actions", strings.Join(a.Actions, ","))
+	for _, action := range a.Actions {
+		data.Set("action."+action, "1")
+	}
+	for k, v := range s.Params {
+		data.Set(k, v)
+	}
+	return data
+}
+
+// savedSearchEntry is an entry of the saved/searches endpoints
+type savedSearchEntry struct {
+	Name string `json:"name"`
+	ACL  struct {
+		App   string `json:"app"`
+		Owner string `json:"owner"`
+	} `json:"acl"`
+	Content map[string]interface{} `json:"content"`
+}
+
+func (e savedSearchEntry) savedSearch() SavedSearch {
+	m := e.Content
+	s := SavedSearch{
+		Name:        e.Name,
+		App:         e.ACL.App,
+		Owner:       e.ACL.Owner,
+		Search:      contentString(m, "search"),
+		Description: contentString(m, "description"),
+		Disabled:    contentBool(m, "disabled"),
+		Earliest:    contentString(m, "dispatch.earliest_time"),
+		Latest:      contentString(m, "dispatch.latest_time"),
+	}
+	s.content = map[string]string{}
+	for k := range m {
+		s.content[k] = contentString(m, k)
+	}
+	if contentBool(m, "is_scheduled") {
+		s.CronSchedule = contentString(m, "cron_schedule")
+	}
+	a := Alert{
+		Type:       contentString(m, "alert_type"),
+		Comparator: contentString(m, "alert_comparator"),
+		Threshold:  contentString(m, "alert_threshold"),
+		Condition:  contentString(m, "alert_condition"),
+	}
+	a.Severity, _ = strconv.Atoi(contentString(m, "alert.severity"))
+	if contentBool(m, "alert.suppress") {
+		a.Suppress = contentString(m, "alert.suppress.period")
+	}
+	for _, action := range strings.Split(contentString(m, "actions"), ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			a.Actions = append(a.Actions, action)
+		}
+	}
+	if len(a.Actions) > 0 || (a.Type != "" && a.Type != "always") {
+		s.Alert = &a
+	}
+	for _, action := range a.Actions {
+		prefix := "action." + action + "."
+		for k := range m {
+			if v := contentString(m, k); strings.HasPrefix(k, prefix) && v != "" {
+				if s.Params == nil {
+					s.Params = map[string]string{}
+				}
+				s.Params[k] = v
+			}
+		}
+	}
+	return s
+}
+
+// contentString returns the attribute `key` of an entry's content as a
+// string, whatever its JSON type
+func contentString(m map[string]interface{}, key string) string {
+	switch v := m[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		byts, _ := json.Marshal(v)
+		return string(byts)
+	}
+}
+
+// contentBool reads a boolean attribute, which splunk returns as a JSON
+// boolean, a number or a string such as "1"
+func contentBool(m map[string]interface{}, key string) bool {
+	switch v := m[key].(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
+func parseSavedSearches(body []byte) ([]SavedSearch, error) {
+	var resp struct {
+		Entry []savedSearchEntry `json:"entry"`
+	}
+	err := json.Unmarshal(body, &resp)
+	if err != nil {
+		return nil, err
+	}
+	var ret []SavedSearch
+	for _, e := range resp.Entry {
+		ret = append(ret, e.savedSearch())
+	}
+	return ret, nil
+}
+
+// ListSavedSearches returns the saved searches in `ns` the client's user
+// can see, sorted by app and name
+func (c *Client) ListSavedSearches(ns Namespace) ([]SavedSearch, error) {
+	return c.ListSavedSearchesContext(context.Background(), ns)
+}
+
+func (c *Client) ListSavedSearchesContext(ctx context.Context, ns Namespace) ([]SavedSearch, error) {
+	// curl -H "Authorization: Splunk $SPLUNK_SESSION" -X GET https://splunk.sendgrid.net:8089/servicesNS/-/search/saved/searches -d output_mode=json -d count=0
+	data := jsonParams()
+	data.Set("count", "0")
+	r, err := c.callOK(ctx, request{method: "GET", path: ns.path("/saved/searches"), data: data})
+	if err != nil {
+		return nil, err
+	}
+	ret, err := parseSavedSearches(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].App != ret[j].App {
+			return ret[i].App < ret[j].App
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return ret, nil
+}
+
+// GetSavedSearch returns the saved search `name` in `ns`. It is an
+// error if more than one app has a saved search by that name
+func (c *Client) GetSavedSearch(ns Namespace, name string) (SavedSearch, error) {
+	return c.GetSavedSearchContext(context.Background(), ns, name)
+}
+
+func (c *Client) GetSavedSearchContext(ctx context.Context, ns Namespace, name string) (SavedSearch, error) {
+	r, err := c.callOK(ctx, request{method: "GET", path: savedSearchPath(ns, name), data: jsonParams()})
+	if err != nil {
+		return SavedSearch{}, err
+	}
+	found, err := parseSavedSearches(r.Body)
+	if err != nil {
+		return SavedSearch{}, err
+	}
+	switch len(found) {
+	case 0:
+		return SavedSearch{}, fmt.Errorf("no saved search entry in response")
+	case 1:
+		return found[0], nil
+	}
+	var where []string
+	for _, s := range found {
+		where = append(where, s.Owner+"/"+s.App)
+	}
+	return SavedSearch{}, fmt.Errorf("saved search %q exists in more than one namespace (%s), specify its app and owner", name, strings.Join(where, ", "))
+}
+
+// CreateSavedSearch creates `s` in its app, shared with the app if it
+// has no owner, or else in the default app of the client's user
+func (c *Client) CreateSavedSearch(s SavedSearch) error {
+	return c.CreateSavedSearchContext(context.Background(), s)
+}
+
+func (c *Client) CreateSavedSearchContext(ctx context.Context, s SavedSearch) error {
+	// curl -H "Authorization: Splunk $SPLUNK_SESSION" https://splunk.sendgrid.net:8089/servicesNS/nobody/search/saved/searches -d name=errors -d search='search index=main error' -d cron_schedule='*/15 * * * *' -d is_scheduled=1
+	path := "/services/saved/searches"
+	if s.App != "" {
+		owner := s.Owner
+		if owner == "" {
+			owner = "nobody"
+		}
+		path = Namespace{Owner: owner, App: s.App}.path("/saved/searches")
+	}
+	data := s.values()
+	data.Set("name", s.Name)
+	_, err := c.callOK(ctx, request{method: "POST", path: path, data: data})
+	return err
+}
+
+// UpdateSavedSearch replaces the attributes of the saved search `s.Name`
+// with those of `s`
+func (c *Client) UpdateSavedSearch(s SavedSearch) error {
+	return c.UpdateSavedSearchContext(context.Background(), s)
+}
+
+func (c *Client) UpdateSavedSearchContext(ctx context.Context, s SavedSearch) error {
+	_, err := c.callOK(ctx, request{method: "POST", path: savedSearchPath(s.Namespace(), s.Name), data: s.values()})
+	return err
+}
+
+// DeleteSavedSearch removes the saved search `name` in `ns`
+func (c *Client) DeleteSavedSearch(ns Namespace, name string) error {
+	return c.DeleteSavedSearchContext(context.Background(), ns, name)
+}
+
+func (c *Client) DeleteSavedSearchContext(ctx context.Context, ns Namespace, name string) error {
+	// curl -H "Authorization: Splunk $SPLUNK_SESSION" -X DELETE https://splunk.sendgrid.net:8089/servicesNS/nobody/search/saved/searches/errors
+	_, err := c.callOK(ctx, request{method: "DELETE", path: savedSearchPath(ns, name), data: jsonParams()})
+	return err
+}
+
+// DispatchSavedSearch runs the saved search `name` now and returns the
+// search ID of its job. The earliest_time and latest_time set by opts,
+// e.g. WithTimeRange, override the saved search's time range; use
+// WithParam("trigger_actions", "1") to run its alert actions
+func (c *Client) DispatchSavedSearch(ns Namespace, name string, opts ...Option) (string, error) {
+	return c.DispatchSavedSearchContext(context.Background(), ns, name, opts...)
+}
+
+func (c *Client) DispatchSavedSearchContext(ctx context.Context, ns Namespace, name string, opts ...Option) (string, error) {
+	// curl -H "Authorization: Splunk $SPLUNK_SESSION" https://splunk.sendgrid.net:8089/servicesNS/nobody/search/saved/searches/errors/dispatch -d dispatch.earliest_time=-1h
+	data := jsonParams(opts...)
+	for _, key := range []string{"earliest_time", "latest_time"} {
+		if v := data.Get(key); v != "" {
+			data.Set("dispatch."+key, v)
+			data.Del(key)
+		}
+	}
+	r, err := c.callOK(ctx, request{method: "POST", path: savedSearchPath(ns, name) + "/dispatch", data: data})
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		SearchID string `json:"sid"`
+	}
+	err = json.Unmarshal(r.Body, &resp)
+	if err != nil {
+		return "", err
+	}
+	if resp.SearchID == "" {
+		return "", fmt.Errorf("no sid in dispatch response")
+	}
+	params := url.Values{}
+	if v := data.Get("dispatch.earliest_time"); v != "" {
+		params.Set("earliest_time", v)
+	}
+	if v := data.Get("dispatch.latest_time"); v != "" {
+		params.Set("latest_time", v)
+	}
+	if err := c.recordSearch(resp.SearchID, fmt.Sprintf("| savedsearch %q", name), params); err != nil {
+		Warnf("unable to record search %s in history: %s", resp.SearchID, err.Error())
+	}
+	return resp.SearchID, nil
+}
+
+// SavedSearchHistory returns the jobs of the saved search `name` in `ns`
+// still on the server, scheduled or dispatched
+func (c *Client) SavedSearchHistory(ns Namespace, name string) ([]Job, error) {
+	return c.SavedSearchHistoryContext(context.Background(), ns, name)
+}
+
+func (c *Client) SavedSearchHistoryContext(ctx context.Context, ns Namespace, name string) ([]Job, error) {
+	// curl -H "Authorization: Splunk $SPLUNK_SESSION" -X GET https://splunk.sendgrid.net:8089/servicesNS/nobody/search/saved/searches/errors/history -d output_mode=json
+	r, err := c.callOK(ctx, request{method: "GET", path: savedSearchPath(ns, name) + "/history", data: jsonParams()})
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Entry []struct {
+			Name string `json:"name"`
+			ACL  struct {
+				App   string `json:"app"`
+				Owner string `json:"owner"`
+			} `json:"acl"`
+			Content JobStatus `json:"content"`
+		} `json:"entry"`
+	}
+	err = json.Unmarshal(r.Body, &resp)
+	if err != nil {
+		return nil, err
+	}
+	var ret []Job
+	for _, e := range resp.Entry {
+		j := Job{JobStatus: e.Content, Search: name, Owner: e.ACL.Owner, App: e.ACL.App}
+		if j.SearchID == "" {
+			j.SearchID = e.Name
+		}
+		ret = append(ret, j)
+	}
+	return ret, nil
+}
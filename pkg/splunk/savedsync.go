@@ -0,0 +1,138 @@
+package splunk
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Attrs returns the attributes of the saved search as sent to the
+// server, e.g. cron_schedule or dispatch.earliest_time
+func (s SavedSearch) Attrs() map[string]string {
+	ret := map[string]string{}
+	for k, v := range s.values() {
+		if k != "output_mode" {
+			ret[k] = v[0]
+		}
+	}
+	return ret
+}
+
+// AttrChange is an attribute whose value differs between a saved search
+// definition and the server
+type AttrChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// DiffSavedSearch compares the attributes of `want` to those of `have`.
+// Attributes of `have` that `want` does not set, such as parameters of
+// alert actions, are ignored. Booleans match whatever their spelling,
+// e.g. 1 and true
+func DiffSavedSearch(want, have SavedSearch) []AttrChange {
+	var ret []AttrChange
+	cur := have.Attrs()
+	for k := range want.Params {
+		if v, ok := have.content[k]; ok {
+			cur[k] = v
+		}
+	}
+	for k, v := range want.Attrs() {
+		if !sameAttr(cur[k], v) {
+			ret = append(ret, AttrChange{Name: k, Old: cur[k], New: v})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+func sameAttr(a, b string) bool {
+	if a == b {
+		return true
+	}
+	x, errA := strconv.ParseBool(a)
+	y, errB := strconv.ParseBool(b)
+	return errA == nil && errB == nil && x == y
+}
+
+// Plan actions
+const (
+	PlanCreate = "create"
+	PlanUpdate = "update"
+	PlanDelete = "delete"
+	PlanKeep   = "keep"
+)
+
+// PlanStep is what it takes to make one saved search on the server
+// match its definition
+type PlanStep struct {
+	Action  string      // PlanCreate, PlanUpdate, PlanDelete or PlanKeep
+	Want    SavedSearch // the definition, empty for PlanDelete
+	Have    SavedSearch // the saved search on the server, empty for PlanCreate
+	Changes []AttrChange
+}
+
+// definitionOwner is the owner a definition without one is created with,
+// see CreateSavedSearch
+const definitionOwner = "nobody"
+
+// scope is the owner and app of a saved search, with the owner
+// definitions are created with if it has none
+func (s SavedSearch) scope() string {
+	owner := s.Owner
+	if owner == "" {
+		owner = definitionOwner
+	}
+	return owner + "/" + s.App
+}
+
+// key identifies a saved search by owner, app and name
+func (s SavedSearch) key() string { return s.scope() + "/" + s.Name }
+
+// PlanSavedSearches compares saved search definitions, which must have
+// an app, to the saved searches on the server. With prune, saved searches
+// in the owners and apps of the definitions that no definition mentions
+// are deleted. Steps are ordered by app and name, deletions last
+func PlanSavedSearches(want, have []SavedSearch, prune bool) ([]PlanStep, error) {
+	byKey := map[string]SavedSearch{}
+	for _, s := range have {
+		byKey[s.key()] = s
+	}
+	var ret []PlanStep
+	seen := map[string]bool{}
+	managed := map[string]bool{}
+	for _, w := range want {
+		if w.App == "" {
+			return nil, fmt.Errorf("saved search %q has no app", w.Name)
+		}
+		k := w.key()
+		if seen[k] {
+			return nil, fmt.Errorf("saved search %q is defined more than once in app %s", w.Name, w.App)
+		}
+		seen[k] = true
+		managed[w.scope()] = true
+		h, ok := byKey[k]
+		if !ok {
+			ret = append(ret, PlanStep{Action: PlanCreate, Want: w})
+			continue
+		}
+		step := PlanStep{Action: PlanKeep, Want: w, Have: h, Changes: DiffSavedSearch(w, h)}
+		if len(step.Changes) > 0 {
+			step.Action = PlanUpdate
+		}
+		ret = append(ret, step)
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Want.key() < ret[j].Want.key() })
+	if !prune {
+		return ret, nil
+	}
+	var deletes []PlanStep
+	for _, h := range have {
+		if managed[h.scope()] && !seen[h.key()] {
+			deletes = append(deletes, PlanStep{Action: PlanDelete, Have: h})
+		}
+	}
+	sort.SliceStable(deletes, func(i, j int) bool { return deletes[i].Have.key() < deletes[j].Have.key() })
+	return append(ret, deletes...), nil
+}
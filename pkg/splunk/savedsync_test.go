@@ -0,0 +1,124 @@
+package splunk
+
+import (
+	"reflect"
+	"testing"
+)
+
+// serverSearch decodes a saved search the way it is read from the server
+func serverSearch(t *testing.T, body string) SavedSearch {
+	t.Helper()
+	ret, err := parseSavedSearches([]byte(body))
+	if err != nil || len(ret) != 1 {
+		t.Fatalf("parseSavedSearches(%s) = %v, %v", body, ret, err)
+	}
+	return ret[0]
+}
+
+func TestDiffSavedSearch(t *testing.T) {
+	have := serverSearch(t, `{"entry":[{"name":"errors","acl":{"app":"search","owner":"nobody"},"content":{
+		"search":"search index=main error","is_scheduled":true,"cron_schedule":"*/5 * * * *",
+		"dispatch.earliest_time":"-5m","dispatch.latest_time":"now","dispatch.ttl":"2p",
+		"alert.digest_mode":true,"alert_type":"always","actions":"email","action.email":true,
+		"action.email.to":"ops@example.com","disabled":false}}]}`)
+	base := SavedSearch{Name: "errors", App: "search", Search: "search index=main error",
+		CronSchedule: "*/5 * * * *", Earliest: "-5m", Latest: "now",
+		Alert: &Alert{Actions: []string{"email"}}}
+	with := func(f func(s *SavedSearch)) SavedSearch {
+		s := base
+		a := *base.Alert
+		s.Alert = &a
+		f(&s)
+		return s
+	}
+	tests := []struct {
+		name string
+		want SavedSearch
+		diff []AttrChange
+	}{
+		{"same", base, nil},
+		{"other params", with(func(s *SavedSearch) {
+			s.Params = map[string]string{"dispatch.ttl": "2p", "alert.digest_mode": "1", "action.email.to": "ops@example.com"}
+		}), nil},
+		{"changed param", with(func(s *SavedSearch) {
+			s.Params = map[string]string{"dispatch.ttl": "10p"}
+		}), []AttrChange{{"dispatch.ttl", "2p", "10p"}}},
+		{"param the server lacks", with(func(s *SavedSearch) {
+			s.Params = map[string]string{"dispatch.max_count": "100"}
+		}), []AttrChange{{"dispatch.max_count", "", "100"}}},
+		{"changed search and schedule", with(func(s *SavedSearch) {
+			s.Search = "search index=main fatal"
+			s.CronSchedule = "*/15 * * * *"
+		}), []AttrChange{{"cron_schedule", "*/5 * * * *", "*/15 * * * *"}, {"search", "search index=main error", "search index=main fatal"}}},
+	}
+	for _, tt := range tests {
+		if got := DiffSavedSearch(tt.want, have); !reflect.DeepEqual(got, tt.diff) {
+			t.Errorf("%s: DiffSavedSearch = %v, want %v", tt.name, got, tt.diff)
+		}
+	}
+}
+
+func TestPlanSavedSearches(t *testing.T) {
+	have := []SavedSearch{
+		{Name: "a", App: "search", Owner: "nobody", Search: "search index=a"},
+		{Name: "b", App: "search", Owner: "nobody", Search: "search index=b"},
+		{Name: "stale", App: "search", Owner: "nobody", Search: "search index=old"},
+		{Name: "private", App: "search", Owner: "alice", Search: "search index=p"},
+		{Name: "other", App: "ops", Owner: "nobody", Search: "search index=o"},
+	}
+	want := []SavedSearch{
+		{Name: "b", App: "search", Search: "search index=b2"},
+		{Name: "new", App: "search", Search: "search index=n"},
+		{Name: "a", App: "search", Search: "search index=a"},
+	}
+	type step struct{ action, name string }
+	summarize := func(steps []PlanStep) []step {
+		var ret []step
+		for _, s := range steps {
+			name := s.Want.Name
+			if s.Action == PlanDelete {
+				name = s.Have.Owner + "/" + s.Have.Name
+			}
+			ret = append(ret, step{s.Action, name})
+		}
+		return ret
+	}
+	tests := []struct {
+		name  string
+		prune bool
+		steps []step
+	}{
+		{"keep others", false, []step{{PlanKeep, "a"}, {PlanUpdate, "b"}, {PlanCreate, "new"}}},
+		// only the nobody/search scope of the definitions is pruned
+		{"prune", true, []step{{PlanKeep, "a"}, {PlanUpdate, "b"}, {PlanCreate, "new"}, {PlanDelete, "nobody/stale"}}},
+	}
+	for _, tt := range tests {
+		steps, err := PlanSavedSearches(want, have, tt.prune)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := summarize(steps); !reflect.DeepEqual(got, tt.steps) {
+			t.Errorf("%s: PlanSavedSearches = %v, want %v", tt.name, got, tt.steps)
+		}
+	}
+
+	steps, _ := PlanSavedSearches(want, have, false)
+	if want := []AttrChange{{"search", "search index=b", "search index=b2"}}; !reflect.DeepEqual(steps[1].Changes, want) {
+		t.Errorf("changes of b = %v, want %v", steps[1].Changes, want)
+	}
+}
+
+func TestPlanSavedSearchesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		want []SavedSearch
+	}{
+		{"no app", []SavedSearch{{Name: "a", Search: "search x"}}},
+		{"duplicate", []SavedSearch{{Name: "a", App: "search", Search: "search x"}, {Name: "a", App: "search", Owner: "nobody", Search: "search y"}}},
+	}
+	for _, tt := range tests {
+		if _, err := PlanSavedSearches(tt.want, nil, false); err == nil {
+			t.Errorf("%s: PlanSavedSearches succeeded, want an error", tt.name)
+		}
+	}
+}
@@ -1,22 +1,53 @@
 package splunk
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 )
 
 type Client struct {
-	Username  string            `json:"username"`
-	SessionID string            `json:"session_id"`
-	Addr      string            `json:"addr"`
-	Searches  map[string]string `json:"searches"`
-	httpcli   http.Client
+	Username  string `json:"username"`
+	SessionID string `json:"session_id"`
+	Addr      string `json:"addr"`
+	Profile   string `json:"-"`
+
+	// History, if set, records every search submitted
+	History *History `json:"-"`
+
+	// AuthType is one of AuthSession (the default), AuthToken or
+	// AuthBasic. Auth, if set, overrides it
+	AuthType string        `json:"auth_type,omitempty"`
+	Token    string        `json:"token,omitempty"`
+	TokenID  string        `json:"token_id,omitempty"` // set when Token was created by this cli
+	Password string        `json:"password,omitempty"`
+	Auth     Authenticator `json:"-"`
+
+	// Store selects where secrets are kept, see OpenCredentialStore
+	Store       string          `json:"credential_store,omitempty"`
+	Credentials CredentialStore `json:"-"`
+
+	// Reauth, if set, renews an expired session, see ReauthWith
+	Reauth ReauthFunc `json:"-"`
+
+	// TLS, if set, configures certificate verification and mTLS
+	TLS *TLSOptions `json:"tls,omitempty"`
+	// HTTP, if set, configures proxying, timeouts and keep-alives
+	HTTP *HTTPOptions `json:"http,omitempty"`
+	// Retry, if set, replaces DefaultRetryPolicy. OnRetry, if set, is
+	// called before waiting for each retry
+	Retry   *RetryPolicy     `json:"retry,omitempty"`
+	OnRetry func(RetryEvent) `json:"-"`
+
+	httpcli *http.Client
+	stored  map[string]string // secrets as last read from or written to Credentials
+	// movedFrom is the store the secrets are erased from once saved
+	// to another, see UseCredentialStore
+	movedFrom string
 }
 
 func (c *Client) ToJSON() string {
@@ -24,38 +55,142 @@ func (c *Client) ToJSON() string {
 	return string(byts)
 }
 
-func (c *Client) SaveTo(fileloc string) error {
-	err := ioutil.WriteFile(fileloc, []byte(c.ToJSON()), 0644)
-	if err != nil {
-		return err
+// MarshalJSON leaves out secrets that belong in a credential store
+func (c *Client) MarshalJSON() ([]byte, error) {
+	type client Client
+	cp := client(*c)
+	if cp.Store != "" {
+		cp.SessionID, cp.Token, cp.Password = "", "", ""
+	}
+	return json.Marshal(cp)
+}
+
+func (c *Client) credentialKey(name string) string {
+	profile := c.Profile
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	return profile + "/" + name
+}
+
+// secrets lists the fields kept in the credential store by name
+func (c *Client) secrets() map[string]*string {
+	return map[string]*string{"session_id": &c.SessionID, "token": &c.Token, "password": &c.Password}
+}
+
+// loadCredentials opens the profile's credential store and reads its secrets
+func (c *Client) loadCredentials(configloc string) error {
+	if c.Store == "" {
+		return nil
+	}
+	var err error
+	if c.Credentials == nil {
+		c.Credentials, err = OpenCredentialStore(c.Store, configloc)
+		if err != nil {
+			return err
+		}
+	}
+	c.stored = make(map[string]string)
+	for name, field := range c.secrets() {
+		secret, err := c.Credentials.Get(c.credentialKey(name))
+		if err == ErrCredentialNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		*field = secret
+		c.stored[name] = secret
 	}
 	return nil
 }
 
-func LoadClient(fileloc string) (*Client, error) {
-	ret := Client{Searches: make(map[string]string)}
+// saveCredentials writes the secrets that changed since they were loaded
+func (c *Client) saveCredentials(configloc string) error {
+	if c.Store == "" {
+		return nil
+	}
+	var err error
+	if c.Credentials == nil {
+		c.Credentials, err = OpenCredentialStore(c.Store, configloc)
+		if err != nil {
+			return err
+		}
+	}
+	if c.stored == nil {
+		c.stored = make(map[string]string)
+	}
+	for name, field := range c.secrets() {
+		stored, ok := c.stored[name]
+		if stored == *field && (ok || *field == "") {
+			continue
+		}
+		err = c.Credentials.Store(c.credentialKey(name), *field)
+		if err != nil {
+			return err
+		}
+		c.stored[name] = *field
+	}
+	return nil
+}
 
-	// open and parse json settings file
-	file, err := os.Open(fileloc)
+// SaveTo stores the client as its profile in the config file at
+// fileloc, leaving the other profiles untouched. Secrets go to the
+// profile's credential store when one is set. From then on searches are
+// recorded in the history next to fileloc
+func (c *Client) SaveTo(fileloc string) error {
+	if c.History == nil {
+		c.History = OpenHistory(HistoryPath(fileloc))
+	}
+	cfg, err := loadOrNewConfig(fileloc)
 	if err != nil {
-		return &ret, err
+		return err
+	}
+	name := c.Profile
+	if name == "" {
+		name = DefaultProfile
 	}
-	defer file.Close()
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(&ret)
+	cfg.SetClient(name, c)
+	err = c.saveCredentials(fileloc)
 	if err != nil {
-		return &ret, err
+		return err
+	}
+	err = cfg.SaveTo(fileloc)
+	if err != nil || c.movedFrom == "" || c.movedFrom == c.Store {
+		return err
 	}
-	return &ret, nil
+	err = c.eraseCredentials(c.movedFrom, fileloc)
+	if err != nil {
+		return fmt.Errorf("unable to erase the credentials of profile %s from %s: %s", name, c.movedFrom, err.Error())
+	}
+	c.movedFrom = ""
+	return nil
 }
 
-func New(addr string) *Client { return &Client{Addr: addr, Searches: make(map[string]string)} }
+// LoadClient loads the client of the current profile from fileloc
+func LoadClient(fileloc string) (*Client, error) { return LoadProfile(fileloc, "") }
+
+// ClientOption configures a Client in New
+type ClientOption func(*Client)
+
+func New(addr string, opts ...ClientOption) *Client {
+	c := &Client{Addr: addr}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
 
-//  NewSessionID attempts to authenticate with `addr` using `username`
-//  and `password` and returns a sessionID if successful. An optional
-//  `*http.Client` `cli` may be passed in. If nil, a default one will
-//  be used
+// NewSessionID attempts to authenticate with `addr` using `username`
+// and `password` and returns a sessionID if successful. An optional
+// `*http.Client` `cli` may be passed in. If nil, a default one will
+// be used
 func NewSessionID(addr, username, password string, httpClient *http.Client) (string, error) {
+	return NewSessionIDContext(context.Background(), addr, username, password, httpClient)
+}
+
+// NewSessionIDContext is NewSessionID with a context bounding the login
+func NewSessionIDContext(ctx context.Context, addr, username, password string, httpClient *http.Client) (string, error) {
 	// curl https://splunk.sendgrid.net:8089/services/auth/login -d username=$SPLUNK_USER -d password="$SPLUNK_PASS"
 	cli := http.Client{}
 	if httpClient != nil {
@@ -71,7 +206,7 @@ func NewSessionID(addr, username, password string, httpClient *http.Client) (str
 		return "", err
 	}
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	resp, err := cli.Do(req)
+	resp, err := cli.Do(req.WithContext(ctx))
 	if err != nil {
 		return "", err
 	}
@@ -80,7 +215,7 @@ func NewSessionID(addr, username, password string, httpClient *http.Client) (str
 		return "", err
 	}
 	if resp.StatusCode/100 != 2 {
-		return "", fmt.Errorf("non-200 return code: %d, response: %s", resp.StatusCode, string(byt))
+		return "", newAPIError("POST", "/services/auth/login", Response{Body: byt, StatusCode: resp.StatusCode})
 	}
 	type expectedResposne struct {
 		SessionKey string `json:"sessionKey"`
@@ -94,12 +229,21 @@ func NewSessionID(addr, username, password string, httpClient *http.Client) (str
 }
 
 func (c *Client) RenewSessionID(username, password string) (string, error) {
-	sid, err := NewSessionID(c.Addr, username, password, &c.httpcli)
+	return c.RenewSessionIDContext(context.Background(), username, password)
+}
+
+func (c *Client) RenewSessionIDContext(ctx context.Context, username, password string) (string, error) {
+	httpcli, err := c.httpClient()
+	if err != nil {
+		return "", err
+	}
+	sid, err := NewSessionIDContext(ctx, c.Addr, username, password, httpcli)
 	if err != nil {
 		return "", err
 	}
 	c.SessionID = sid
 	c.Username = username
+	c.AuthType = AuthSession
 	return sid, nil
 }
 
@@ -112,36 +256,28 @@ type SearchResponse struct {
 
 // TODO ensure search has a date
 func (c *Client) Search(search string, opts ...Option) (SearchResponse, error) {
+	return c.SearchContext(context.Background(), search, opts...)
+}
+
+// SearchContext submits a search job. The context bounds the submission
+// only, the job keeps running once created
+func (c *Client) SearchContext(ctx context.Context, search string, opts ...Option) (SearchResponse, error) {
 	// curl -H "Authorization: Splunk $SPLUNK_SESSION"
 	//      https://splunk.sendgrid.net:8089/services/search/jobs
 	//      -d output_mode=json
 	//      -d search='search earliest=-4h event=processed | eval l=len(subject) | where l > 3000'
 	var ret SearchResponse
-	urlstr := fmt.Sprintf("%s/services/search/jobs", c.Addr)
 	data := url.Values{}
 	for _, opt := range opts {
 		opt(data)
 	}
 	data.Set("output_mode", "json")
 	data.Set("search", search)
-	req, err := http.NewRequest("POST", urlstr, strings.NewReader(data.Encode()))
+	var err error
+	ret.Response, err = c.callOK(ctx, request{method: "POST", path: "/services/search/jobs", data: data})
 	if err != nil {
 		return ret, err
 	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", fmt.Sprintf("Splunk %s", c.SessionID))
-	resp, err := c.httpcli.Do(req)
-	if err != nil {
-		return ret, err
-	}
-	ret.Body, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return ret, err
-	}
-	ret.StatusCode = resp.StatusCode
-	if ret.AuthFailed() {
-		return ret, ErrAuth
-	}
 
 	type expectedResposne struct {
 		SearchID string `json:"sid"`
@@ -151,8 +287,10 @@ func (c *Client) Search(search string, opts ...Option) (SearchResponse, error) {
 	if err != nil {
 		return ret, err
 	}
-	c.Searches[exp.SearchID] = search
-	ret.SearchID = search
+	ret.SearchID = exp.SearchID
+	if err := c.recordSearch(ret.SearchID, search, data); err != nil {
+		Warnf("unable to record search %s in history: %s", ret.SearchID, err.Error())
+	}
 	return ret, nil
 }
 
@@ -163,38 +301,13 @@ func WithParam(key string, value string) Option {
 type Option func(url.Values)
 
 func (c *Client) GetSearchResults(searchID string, opts ...Option) (Response, error) {
-	// curl -H "Authorization: Splunk $SPLUNK_SESSION" -X GET https://splunk.sendgrid.net:8089/services/search/jobs/$SEARCH_ID/results -d output_mode=json
-
-	var ret Response
-
-	urlstr := fmt.Sprintf("%s/services/search/jobs/%s/results", c.Addr, searchID)
-	req, err := http.NewRequest("GET", urlstr, nil)
-	if err != nil {
-		return ret, err
-	}
-
-	data := url.Values{}
-	for _, opt := range opts {
-		opt(data)
-	}
-	if _, ok := data["output_mode"]; !ok {
-		data.Set("output_mode", "json")
-	}
-
-	req.URL.RawQuery = data.Encode()
-	req.Header.Add("Authorization", fmt.Sprintf("Splunk %s", c.SessionID))
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	return c.GetSearchResultsContext(context.Background(), searchID, opts...)
+}
 
-	resp, err := c.httpcli.Do(req)
-	if err != nil {
-		return ret, err
-	}
-	ret.Body, err = ioutil.ReadAll(resp.Body)
-	ret.StatusCode = resp.StatusCode
-	if err != nil {
-		return ret, err
-	}
-	return ret, nil
+func (c *Client) GetSearchResultsContext(ctx context.Context, searchID string, opts ...Option) (Response, error) {
+	// curl -H "Authorization: Splunk $SPLUNK_SESSION" -X GET https://splunk.sendgrid.net:8089/services/search/jobs/$SEARCH_ID/results -d output_mode=json
+	path := fmt.Sprintf("/services/search/jobs/%s/results", searchID)
+	return c.call(ctx, request{method: "GET", path: path, data: jsonParams(opts...)})
 }
 
 type Response struct {
@@ -202,61 +315,62 @@ type Response struct {
 	StatusCode int
 }
 
-var ErrAuth = fmt.Errorf("call not properly authenticated")
-
+// AuthFailed reports whether splunk rejected the credentials. It only
+// answers 401 for missing, expired or invalid ones
 func (r *Response) AuthFailed() bool {
-	return r.StatusCode == 401 && bytes.Index(r.Body, []byte(ErrAuth.Error())) >= 0 // TODO this is a weak check
+	return r.StatusCode == http.StatusUnauthorized
 }
 
-// TODO have --raw feature to give back what splunk gives you, and also have expected struct here
+// GetSearchStatus returns the raw job entry splunk gives back. See
+// GetJobStatus for the decoded form
 func (c *Client) GetSearchStatus(searchID string) (Response, error) {
+	return c.GetSearchStatusContext(context.Background(), searchID)
+}
+
+func (c *Client) GetSearchStatusContext(ctx context.Context, searchID string) (Response, error) {
 	// # check status of search
 	// curl -H "Authorization: Splunk $SPLUNK_SESSION"  https://splunk.sendgrid.net:8089/services/search/jobs/$SEARCH_ID -d output_mode=json
+	path := fmt.Sprintf("/services/search/jobs/%s", searchID)
+	return c.call(ctx, request{method: "GET", path: path, data: jsonParams()})
+}
 
-	var ret Response
+// ClearKnownSearches marks the history entries of this profile whose
+// job no longer exists on the server as StatusExpired, asking about each
+// entry not yet expired
+func (c *Client) ClearKnownSearches() error {
+	return c.ClearKnownSearchesContext(context.Background())
+}
 
-	urlstr := fmt.Sprintf("%s/services/search/jobs/%s", c.Addr, searchID)
-	data := url.Values{}
-	data.Set("output_mode", "json")
-	req, err := http.NewRequest("GET", urlstr, strings.NewReader(data.Encode()))
-	if err != nil {
-		return ret, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", fmt.Sprintf("Splunk %s", c.SessionID))
-	resp, err := c.httpcli.Do(req)
-	if err != nil {
-		return ret, err
+func (c *Client) ClearKnownSearchesContext(ctx context.Context) error {
+	if c.History == nil {
+		return nil
 	}
-	ret.Body, err = ioutil.ReadAll(resp.Body)
+	entries, err := c.History.Entries()
 	if err != nil {
-		return ret, err
-	}
-	ret.StatusCode = resp.StatusCode
-
-	if ret.AuthFailed() {
-		return ret, ErrAuth
+		return err
 	}
-
-	return ret, nil
-}
-
-func (c *Client) ClearKnownSearches() error {
-	var rm []string
-	for sid, _ := range c.Searches {
-		_, err := c.GetSearchStatus(sid)
-		if err == ErrAuth {
-			// dont clear if we can't communicate
-			return ErrAuth
+	changed := false
+	for i, e := range entries {
+		if e.Profile != c.Profile || e.Addr != c.Addr || e.Status == StatusExpired {
+			continue
+		}
+		r, err := c.GetSearchStatusContext(ctx, e.SearchID)
+		if err == nil && r.StatusCode/100 != 2 && r.StatusCode != http.StatusNotFound {
+			err = newAPIError("GET", "/services/search/jobs/"+e.SearchID, r)
 		}
 		if err != nil {
-			rm = append(rm, sid)
+			// dont clear if we can't communicate
+			return err
+		}
+		if r.StatusCode == http.StatusNotFound {
+			entries[i].Status = StatusExpired
+			changed = true
 		}
 	}
-	for _, sid := range rm {
-		delete(c.Searches, sid)
+	if !changed {
+		return nil
 	}
-	return nil
+	return c.History.save(entries)
 }
 
 // TODO
@@ -277,7 +391,7 @@ func submitRequest(req *http.Request) ([]byte, error) {
 	}
 
 	if resp.StatusCode/100 != 2 {
-		err = fmt.Errorf("non-200 return code: %d, response: %s", resp.StatusCode, string(byt))
+		err = newAPIError(req.Method, req.URL.Path, Response{Body: byt, StatusCode: resp.StatusCode})
 	}
 
 	return byt, err
@@ -0,0 +1,191 @@
+package splunk
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeUnits are the units splunk accepts in relative time modifiers
+var timeUnits = map[string]bool{
+	"s": true, "sec": true, "secs": true, "second": true, "seconds": true,
+	"m": true, "min": true, "mins": true, "minute": true, "minutes": true,
+	"h": true, "hr": true, "hrs": true, "hour": true, "hours": true,
+	"d": true, "day": true, "days": true,
+	"w": true, "week": true, "weeks": true,
+	"mon": true, "month": true, "months": true,
+	"q": true, "qtr": true, "qtrs": true, "quarter": true, "quarters": true,
+	"y": true, "yr": true, "yrs": true, "year": true, "years": true,
+}
+
+// snapUnit reports whether `u` may follow @, which also takes w0 to w7
+// to snap to a day of the week
+func snapUnit(u string) bool {
+	if len(u) == 2 && u[0] == 'w' && u[1] >= '0' && u[1] <= '7' {
+		return true
+	}
+	return timeUnits[u]
+}
+
+var epochTime = regexp.MustCompile(`^\d+(\.\d+)?$`)
+
+// splunkTimeFormat is the absolute time format splunk accepts for the
+// earliest= and latest= search modifiers
+const splunkTimeFormat = "01/02/2006:15:04:05"
+
+// ValidateTime checks that `s` is a time splunk accepts for earliest and
+// latest: now, epoch seconds, splunk's %m/%d/%Y:%H:%M:%S or a relative
+// time such as -4h, -1d@d, @w1 or -30m@h+5m
+func ValidateTime(s string) error {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "now" || epochTime.MatchString(s) {
+		return nil
+	}
+	if _, err := time.Parse(splunkTimeFormat, s); err == nil {
+		return nil
+	}
+	rel := strings.TrimPrefix(s, "rt")
+	if rel == "" {
+		return fmt.Errorf("invalid time %q", s)
+	}
+	for rel != "" {
+		var err error
+		rel, err = relativeTerm(rel)
+		if err != nil {
+			return fmt.Errorf("invalid time %q: %s", s, err.Error())
+		}
+	}
+	return nil
+}
+
+// relativeTerm consumes one offset, e.g. -4h, or snap, e.g. @d, from the
+// front of `s`
+func relativeTerm(s string) (string, error) {
+	switch s[0] {
+	case '@':
+		unit, rest := leading(s[1:], isLetterOrDigit)
+		if !snapUnit(unit) {
+			return "", fmt.Errorf("unknown snap unit %q", "@"+unit)
+		}
+		return rest, nil
+	case '+', '-':
+		_, rest := leading(s[1:], isDigit)
+		unit, rest := leading(rest, isLetter)
+		if !timeUnits[unit] {
+			return "", fmt.Errorf("unknown time unit %q in %q", unit, s[:len(s)-len(rest)])
+		}
+		return rest, nil
+	}
+	return "", fmt.Errorf("expected now, an offset such as -4h or a snap such as @d at %q", s)
+}
+
+func leading(s string, fn func(byte) bool) (string, string) {
+	i := 0
+	for i < len(s) && fn(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func isDigit(b byte) bool         { return b >= '0' && b <= '9' }
+func isLetter(b byte) bool        { return b >= 'a' && b <= 'z' }
+func isLetterOrDigit(b byte) bool { return isLetter(b) || isDigit(b) }
+
+// absoluteTimeFormats are the layouts NormalizeTime reads in local time
+var absoluteTimeFormats = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	splunkTimeFormat,
+}
+
+// NormalizeTime validates `s` for the earliest_time and latest_time job
+// parameters. Absolute times, RFC 3339 or e.g. 2026-10-01T00:00 in local
+// time, are converted to epoch seconds; anything else must pass
+// ValidateTime and is returned as is
+func NormalizeTime(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return strconv.FormatInt(t.Unix(), 10), nil
+	}
+	for _, layout := range absoluteTimeFormats {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return strconv.FormatInt(t.Unix(), 10), nil
+		}
+	}
+	if err := ValidateTime(s); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+// WithTimeRange sets the earliest_time and latest_time job parameters,
+// leaving out those that are empty. See NormalizeTime
+func WithTimeRange(earliest, latest string) Option {
+	return func(v url.Values) {
+		if earliest != "" {
+			v.Set("earliest_time", earliest)
+		}
+		if latest != "" {
+			v.Set("latest_time", latest)
+		}
+	}
+}
+
+var timeModifier = regexp.MustCompile(`^(?i)(earliest|latest)\s*=\s*("(?:[^"\\]|\\.)*"|[^\s()\[\]|]+)`)
+
+// InlineTimeRange returns the earliest= and latest= modifiers of the base
+// search, the part before the first pipe. Those in quoted strings,
+// subsearches and later commands, e.g. eval latest=now(), are ignored
+func InlineTimeRange(search string) (earliest, latest string) {
+	depth := 0
+	for i := 0; i < len(search); i++ {
+		switch c := search[i]; {
+		case c == '"':
+			i = closingQuote(search, i)
+		case c == '[':
+			depth++
+		case c == ']':
+			if depth > 0 {
+				depth--
+			}
+		case depth > 0:
+		case c == '|':
+			if strings.TrimSpace(search[:i]) != "" {
+				return earliest, latest
+			}
+		case i == 0 || strings.IndexByte(" \t\r\n(", search[i-1]) >= 0:
+			m := timeModifier.FindStringSubmatch(search[i:])
+			if m == nil {
+				continue
+			}
+			v := strings.Trim(m[2], `"`)
+			if strings.EqualFold(m[1], "earliest") {
+				earliest = v
+			} else {
+				latest = v
+			}
+			i += len(m[0]) - 1
+		}
+	}
+	return earliest, latest
+}
+
+// closingQuote returns the index of the double quote closing the one at
+// `i`, or the end of `s` if it is unclosed
+func closingQuote(s string, i int) int {
+	for i++; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return len(s)
+}
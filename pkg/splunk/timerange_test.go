@@ -0,0 +1,79 @@
+package splunk
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateTime(t *testing.T) {
+	valid := []string{
+		"now", "NOW", "0", "1760700000", "1760700000.5", "10/01/2026:00:00:00",
+		"-4h", "-h", "+1d", "-15m@m", "@d", "@w1", "-1w@w0", "-30m@h+5m", "-2months@mon", "rt-5m",
+	}
+	for _, s := range valid {
+		if err := ValidateTime(s); err != nil {
+			t.Errorf("ValidateTime(%q) = %v, want nil", s, err)
+		}
+	}
+	invalid := []string{
+		"", "yesterday", "4h", "-4x", "-4h@", "@w8", "-4h@x", "now()", "10/32/2026:00:00:00", "2026-10-01", "-4h junk",
+	}
+	for _, s := range invalid {
+		if err := ValidateTime(s); err == nil {
+			t.Errorf("ValidateTime(%q) = nil, want an error", s)
+		}
+	}
+}
+
+func TestNormalizeTime(t *testing.T) {
+	defer func(l *time.Location) { time.Local = l }(time.Local)
+	time.Local = time.UTC
+	tests := []struct {
+		in, want string
+		err      bool
+	}{
+		{in: "2026-10-01T00:00:00Z", want: "1790812800"},
+		{in: "2026-10-01T02:00:00+02:00", want: "1790812800"},
+		{in: "2026-10-01T00:00", want: "1790812800"},
+		{in: "2026-10-01 00:00:30", want: "1790812830"},
+		{in: "2026-10-01", want: "1790812800"},
+		{in: "10/01/2026:00:00:00", want: "1790812800"},
+		{in: " -4h@h ", want: "-4h@h"},
+		{in: "now", want: "now"},
+		{in: "1790812800", want: "1790812800"},
+		{in: "2026-13-01", err: true},
+		{in: "last tuesday", err: true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeTime(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("NormalizeTime(%q) = %q, %v, want %q, error %t", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestInlineTimeRange(t *testing.T) {
+	tests := []struct {
+		search           string
+		earliest, latest string
+	}{
+		{`search index=main`, "", ""},
+		{`search index=main earliest=-4h latest=now`, "-4h", "now"},
+		{`search index=main EARLIEST = -4h@h`, "-4h@h", ""},
+		{`search index=main earliest="10/01/2026:00:00:00"`, "10/01/2026:00:00:00", ""},
+		{`search index=main (earliest=-1d latest=-1h)`, "-1d", "-1h"},
+		{`search index=main earliest=-4h | eval latest=now()`, "-4h", ""},
+		{`search index=main | eval latest=now() | where earliest=1`, "", ""},
+		{`search index=main "earliest=-4h" msg="a | latest=now"`, "", ""},
+		{`search index=main msg="say \"hi\" earliest=-1h" latest=-5m`, "", "-5m"},
+		{`search index=main [search index=other earliest=-7d | head 1] earliest=-1h`, "-1h", ""},
+		{`search index=main notearliest=-4h`, "", ""},
+		{`| tstats count where index=main earliest=-1h by host | eval latest=1`, "-1h", ""},
+	}
+	for _, tt := range tests {
+		earliest, latest := InlineTimeRange(tt.search)
+		if earliest != tt.earliest || latest != tt.latest {
+			t.Errorf("InlineTimeRange(%q) = %q, %q, want %q, %q", tt.search, earliest, latest, tt.earliest, tt.latest)
+		}
+	}
+}
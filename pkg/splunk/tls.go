@@ -0,0 +1,65 @@
+package splunk
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// TLSOptions configures how a client verifies the splunk server and
+// identifies itself to it
+type TLSOptions struct {
+	// CAFile is a PEM bundle of CAs trusted in addition to the system's
+	CAFile string `json:"ca_file,omitempty"`
+	// CertFile and KeyFile are a PEM client certificate and key for mTLS
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+	// ServerName overrides the name the server certificate is checked
+	// against, e.g. when connecting by IP
+	ServerName string `json:"server_name,omitempty"`
+	// InsecureSkipVerify disables verification of the server certificate
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// Warnf reports problems that do not stop a request, such as disabled
+// certificate verification. By default it writes to stderr
+var Warnf = func(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "warning: "+format+"\n", args...)
+}
+
+// Config builds the tls.Config described by t
+func (t TLSOptions) Config() (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: t.ServerName, InsecureSkipVerify: t.InsecureSkipVerify}
+	if t.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA bundle: %s", err.Error())
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", t.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return nil, fmt.Errorf("a client certificate needs both a cert file and a key file")
+	}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %s", err.Error())
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// WithTLS sets the TLS settings of the client
+func WithTLS(t TLSOptions) ClientOption {
+	return func(c *Client) { c.TLS = &t }
+}
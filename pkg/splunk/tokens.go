@@ -0,0 +1,126 @@
+package splunk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// TokenInfo describes an authentication token. The token itself is
+// only ever returned by CreateToken
+type TokenInfo struct {
+	ID        string    `json:"id"`
+	User      string    `json:"user"`
+	Audience  string    `json:"audience"`
+	Status    string    `json:"status"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"` // zero if the token never expires
+	LastUsed  time.Time `json:"last_used"`  // zero if the token was never used
+}
+
+// NewToken is a token returned by CreateToken
+type NewToken struct {
+	ID    string `json:"id"`
+	Token string `json:"token"`
+}
+
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// CreateToken creates an authentication token for `user`. `expiresOn`
+// is an absolute or relative time such as "+30d"; empty never expires
+func (c *Client) CreateToken(user, audience, expiresOn string) (NewToken, error) {
+	return c.CreateTokenContext(context.Background(), user, audience, expiresOn)
+}
+
+func (c *Client) CreateTokenContext(ctx context.Context, user, audience, expiresOn string) (NewToken, error) {
+	// curl -H "Authorization: Splunk $SPLUNK_SESSION" https://localhost:8089/services/authorization/tokens -d name=admin -d audience=ci -d expires_on=+30d
+	data := jsonParams()
+	data.Set("name", user)
+	data.Set("audience", audience)
+	if expiresOn != "" {
+		data.Set("expires_on", expiresOn)
+	}
+	r, err := c.callOK(ctx, request{method: "POST", path: "/services/authorization/tokens", data: data})
+	if err != nil {
+		return NewToken{}, err
+	}
+	var resp struct {
+		Entry []struct {
+			Content NewToken `json:"content"`
+		} `json:"entry"`
+	}
+	err = json.Unmarshal(r.Body, &resp)
+	if err != nil {
+		return NewToken{}, err
+	}
+	if len(resp.Entry) == 0 {
+		return NewToken{}, fmt.Errorf("no token entry in response")
+	}
+	return resp.Entry[0].Content, nil
+}
+
+// ListTokens returns the tokens the client's user can see
+func (c *Client) ListTokens() ([]TokenInfo, error) {
+	return c.ListTokensContext(context.Background())
+}
+
+func (c *Client) ListTokensContext(ctx context.Context) ([]TokenInfo, error) {
+	data := jsonParams()
+	data.Set("count", "0")
+	r, err := c.callOK(ctx, request{method: "GET", path: "/services/authorization/tokens", data: data})
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Entry []struct {
+			Name    string `json:"name"`
+			Content struct {
+				Claims struct {
+					Sub string `json:"sub"`
+					Aud string `json:"aud"`
+					Exp int64  `json:"exp"`
+					Iat int64  `json:"iat"`
+				} `json:"claims"`
+				Status   string `json:"status"`
+				LastUsed int64  `json:"lastUsed"`
+			} `json:"content"`
+		} `json:"entry"`
+	}
+	err = json.Unmarshal(r.Body, &resp)
+	if err != nil {
+		return nil, err
+	}
+	var ret []TokenInfo
+	for _, e := range resp.Entry {
+		ret = append(ret, TokenInfo{
+			ID:        e.Name,
+			User:      e.Content.Claims.Sub,
+			Audience:  e.Content.Claims.Aud,
+			Status:    e.Content.Status,
+			IssuedAt:  unixTime(e.Content.Claims.Iat),
+			ExpiresAt: unixTime(e.Content.Claims.Exp),
+			LastUsed:  unixTime(e.Content.LastUsed),
+		})
+	}
+	return ret, nil
+}
+
+// DeleteToken revokes the token `id` belonging to `user`
+func (c *Client) DeleteToken(user, id string) error {
+	return c.DeleteTokenContext(context.Background(), user, id)
+}
+
+func (c *Client) DeleteTokenContext(ctx context.Context, user, id string) error {
+	data := jsonParams()
+	data.Set("id", id)
+	path := fmt.Sprintf("/services/authorization/tokens/%s", url.PathEscape(user))
+	_, err := c.callOK(ctx, request{method: "DELETE", path: path, data: data})
+	return err
+}
@@ -0,0 +1,150 @@
+package splunk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Duration is a time.Duration written to the config file as a string
+// such as "30s"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) { return json.Marshal(time.Duration(d).String()) }
+
+// UnmarshalJSON accepts a duration string or a number of seconds
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var sec float64
+		if err := json.Unmarshal(b, &sec); err != nil {
+			return fmt.Errorf("invalid duration %s", string(b))
+		}
+		*d = Duration(sec * float64(time.Second))
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// HTTPOptions tunes the connections a client makes. Zero values keep
+// the defaults of net/http
+type HTTPOptions struct {
+	// Proxy is the URL of an HTTP(S) proxy. When empty HTTPS_PROXY,
+	// HTTP_PROXY and NO_PROXY are honored; "direct" disables proxying
+	Proxy string `json:"proxy,omitempty"`
+	// Timeout bounds a whole request, including reading the response.
+	// Streaming exports are exempt
+	Timeout               Duration `json:"timeout,omitempty"`
+	DialTimeout           Duration `json:"dial_timeout,omitempty"`
+	ResponseHeaderTimeout Duration `json:"response_header_timeout,omitempty"`
+	// KeepAlive is the TCP keep-alive period, negative disables it
+	KeepAlive           Duration `json:"keep_alive,omitempty"`
+	IdleConnTimeout     Duration `json:"idle_conn_timeout,omitempty"`
+	MaxIdleConnsPerHost int      `json:"max_idle_conns_per_host,omitempty"`
+	DisableKeepAlives   bool     `json:"disable_keep_alives,omitempty"`
+}
+
+func (o HTTPOptions) proxy() (func(*http.Request) (*url.URL, error), error) {
+	switch o.Proxy {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case "direct":
+		return nil, nil
+	}
+	u, err := url.Parse(o.Proxy)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", o.Proxy)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// NewHTTPClient builds an http.Client from TLS and connection settings,
+// either of which may be nil. A warning is printed through Warnf if
+// certificate verification is disabled
+func NewHTTPClient(t *TLSOptions, o *HTTPOptions) (*http.Client, error) {
+	if o == nil {
+		o = &HTTPOptions{}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if t != nil {
+		cfg, err := t.Config()
+		if err != nil {
+			return nil, err
+		}
+		if t.InsecureSkipVerify {
+			Warnf("TLS certificate verification is disabled (insecure_skip_verify)")
+		}
+		transport.TLSClientConfig = cfg
+	}
+	proxy, err := o.proxy()
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = proxy
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if o.DialTimeout != 0 {
+		dialer.Timeout = time.Duration(o.DialTimeout)
+	}
+	if o.KeepAlive != 0 {
+		dialer.KeepAlive = time.Duration(o.KeepAlive)
+	}
+	transport.DialContext = dialer.DialContext
+	transport.ResponseHeaderTimeout = time.Duration(o.ResponseHeaderTimeout)
+	if o.IdleConnTimeout != 0 {
+		transport.IdleConnTimeout = time.Duration(o.IdleConnTimeout)
+	}
+	if o.MaxIdleConnsPerHost != 0 {
+		transport.MaxIdleConnsPerHost = o.MaxIdleConnsPerHost
+	}
+	transport.DisableKeepAlives = o.DisableKeepAlives
+	return &http.Client{Transport: transport, Timeout: time.Duration(o.Timeout)}, nil
+}
+
+// httpClient returns the http.Client the client sends requests with,
+// built from c.TLS and c.HTTP on first use unless WithHTTPClient was
+// given. Changes to the settings after that are ignored
+func (c *Client) httpClient() (*http.Client, error) {
+	if c.httpcli != nil {
+		return c.httpcli, nil
+	}
+	cli, err := NewHTTPClient(c.TLS, c.HTTP)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection settings for %s: %s", c.Addr, err.Error())
+	}
+	c.httpcli = cli
+	return cli, nil
+}
+
+// checkSettings reports invalid TLS or HTTP settings before any request is sent
+func (c *Client) checkSettings() error {
+	if c.TLS != nil {
+		if _, err := c.TLS.Config(); err != nil {
+			return err
+		}
+	}
+	if c.HTTP != nil {
+		if _, err := c.HTTP.proxy(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithHTTPClient makes the client send requests with h instead of one
+// built from its TLS and HTTP settings
+func WithHTTPClient(h *http.Client) ClientOption {
+	return func(c *Client) { c.httpcli = h }
+}
+
+// WithHTTPOptions sets the connection settings of the client
+func WithHTTPOptions(o HTTPOptions) ClientOption {
+	return func(c *Client) { c.HTTP = &o }
+}
@@ -0,0 +1,80 @@
+package splunk
+
+import (
+	"context"
+	"time"
+)
+
+type waitConfig struct {
+	minInterval time.Duration
+	maxInterval time.Duration
+	factor      float64
+	progress    func(JobStatus)
+}
+
+// WaitOption configures WaitForJob
+type WaitOption func(*waitConfig)
+
+// WithPollInterval sets the first and the largest delay between
+// status polls. The delay grows by WithBackoffFactor after each poll
+func WithPollInterval(min, max time.Duration) WaitOption {
+	return func(w *waitConfig) { w.minInterval, w.maxInterval = min, max }
+}
+
+// WithBackoffFactor sets how much the poll delay grows after each
+// poll. A factor of 1 polls at a fixed interval
+func WithBackoffFactor(factor float64) WaitOption {
+	return func(w *waitConfig) { w.factor = factor }
+}
+
+// WithProgress registers a callback that receives every polled status
+func WithProgress(fn func(JobStatus)) WaitOption {
+	return func(w *waitConfig) { w.progress = fn }
+}
+
+// WaitForJob polls the status of searchID until the job is DONE or
+// FAILED, or until ctx is done. The last polled status is returned
+// along with any error, including the job's own failure
+func (c *Client) WaitForJob(ctx context.Context, searchID string, opts ...WaitOption) (JobStatus, error) {
+	cfg := waitConfig{
+		minInterval: 500 * time.Millisecond,
+		maxInterval: 5 * time.Second,
+		factor:      1.5,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.maxInterval < cfg.minInterval {
+		cfg.maxInterval = cfg.minInterval
+	}
+	if cfg.factor < 1 {
+		cfg.factor = 1
+	}
+
+	delay := cfg.minInterval
+	for {
+		status, err := c.GetJobStatusContext(ctx, searchID)
+		if err != nil {
+			return status, err
+		}
+		if cfg.progress != nil {
+			cfg.progress(status)
+		}
+		if status.Finished() {
+			return status, status.Err()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return status, ctx.Err()
+		case <-timer.C:
+		}
+
+		delay = time.Duration(float64(delay) * cfg.factor)
+		if delay > cfg.maxInterval {
+			delay = cfg.maxInterval
+		}
+	}
+}